@@ -0,0 +1,558 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BlocklistSourceHostname  = "hostname"
+	BlocklistSourceSRVTarget = "srv_target"
+	BlocklistSourceIPAddress = "ip_address"
+
+	BlocklistChangeAdded   = "added"
+	BlocklistChangeRemoved = "removed"
+)
+
+const blocklistCacheKey = "blocklist"
+
+var sha1HashRegEx *regexp.Regexp = regexp.MustCompile(`^[0-9a-fA-F]{40}$`)
+
+// blocklistDictionary is the known plaintext patterns of blocked servers list hashes, keyed by hash.
+var blocklistDictionary map[string]string = make(map[string]string)
+
+// blockedServers is the current blocked servers list, which is replaced as a whole on every refresh so that readers never
+// see a partially updated list.
+var blockedServers atomic.Pointer[BlockedServerList]
+
+// BlockedServerList is a snapshot of the SHA-1 hashes of the blocked servers list and the time it was retrieved.
+type BlockedServerList struct {
+	Hashes    *HashSet[string]
+	UpdatedAt time.Time
+}
+
+// BlocklistPattern is a single pattern that an address is checked against in the blocked servers list. The known
+// pattern is the plaintext of the hash from the dictionary of known plaintext patterns, or nil if the hash is not in it.
+type BlocklistPattern struct {
+	Pattern      string  `json:"pattern"`
+	Hash         string  `json:"hash"`
+	Blocked      bool    `json:"blocked"`
+	KnownPattern *string `json:"known_pattern"`
+}
+
+// BlocklistMatch is the address of a server that was found in the blocked servers list, where the address came from,
+// and the pattern that matched it.
+type BlocklistMatch struct {
+	Source  string `json:"source"`
+	Address string `json:"address"`
+	Pattern string `json:"pattern"`
+}
+
+// persistedBlockedServerList is the last successfully retrieved blocked servers list, as stored in Redis and the local
+// file for use when the list cannot be retrieved at startup.
+type persistedBlockedServerList struct {
+	Hashes    []string  `json:"hashes"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// SetBlockedServerList replaces the current blocked servers list.
+func SetBlockedServerList(hashes []string, updatedAt time.Time) {
+	blockedServers.Store(&BlockedServerList{
+		Hashes:    NewHashSet(hashes),
+		UpdatedAt: updatedAt,
+	})
+}
+
+// FetchBlockedServerList retrieves the SHA-1 hashes of the blocked servers from the configured URL.
+func FetchBlockedServerList() ([]string, error) {
+	client := &http.Client{Timeout: config.Blocklist.Timeout}
+
+	resp, err := client.Get(config.Blocklist.URL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("blocklist: unexpected status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]string, 0)
+
+	for _, line := range strings.Split(string(body), "\n") {
+		if line = strings.ToLower(strings.TrimSpace(line)); len(line) > 0 {
+			result = append(result, line)
+		}
+	}
+
+	// An empty list is far more likely to be an outage than every server being unblocked at once
+	if len(result) < 1 {
+		return nil, errors.New("blocklist: response contained no hashes")
+	}
+
+	return result, nil
+}
+
+// RefreshBlockedServerList retrieves the blocked servers list, replaces the current list with it, and persists it as
+// the last good list. Any differences from the previous last good list are recorded as changes.
+func RefreshBlockedServerList() error {
+	hashes, err := FetchBlockedServerList()
+
+	if err != nil {
+		return err
+	}
+
+	// The lock ensures that each change is only recorded once when multiple instances refresh at the same time
+	mutex := r.NewMutex(fmt.Sprintf("%s-lock", blocklistCacheKey))
+
+	locked := true
+
+	if err = mutex.Lock(); err != nil {
+		slog.Warn("Failed to lock blocklist, changes will not be recorded", "error", err)
+
+		locked = false
+	} else {
+		defer mutex.KeepAlive()()
+	}
+
+	previous := loadPersistedBlockedServerList()
+
+	if current := blockedServers.Load(); current != nil && (previous == nil || current.UpdatedAt.After(previous.UpdatedAt)) {
+		previous = &persistedBlockedServerList{
+			Hashes:    current.Hashes.Values(),
+			UpdatedAt: current.UpdatedAt,
+		}
+	}
+
+	now := time.Now().UTC()
+
+	SetBlockedServerList(hashes, now)
+
+	if locked && previous != nil && config.MongoDB != nil {
+		if err = RecordBlocklistChanges(previous.Hashes, hashes, now); err != nil {
+			slog.Error("Failed to record blocklist changes", "error", err)
+		}
+	}
+
+	data, err := json.Marshal(persistedBlockedServerList{
+		Hashes:    hashes,
+		UpdatedAt: now,
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if err = r.Set(blocklistCacheKey, data, 0); err != nil {
+		slog.Warn("Failed to persist blocklist to Redis", "error", err)
+	}
+
+	if len(config.Blocklist.CacheFile) > 0 {
+		if err = WriteFileAtomic(config.Blocklist.CacheFile, data); err != nil {
+			slog.Warn("Failed to persist blocklist to file", "error", err, "file", config.Blocklist.CacheFile)
+		}
+	}
+
+	return nil
+}
+
+// LoadBlockedServerList retrieves the blocked servers list at startup. If it cannot be retrieved, the last good list
+// persisted in Redis or the local file is used instead, whichever is newer, and the refresher will retry later.
+func LoadBlockedServerList() {
+	err := RefreshBlockedServerList()
+
+	if err == nil {
+		slog.Info("Successfully retrieved EULA blocked servers", "size", blockedServers.Load().Hashes.Len())
+
+		return
+	}
+
+	slog.Warn("Failed to retrieve EULA blocked servers, using last good list", "error", err)
+
+	newest := loadPersistedBlockedServerList()
+
+	if newest == nil {
+		slog.Error("No last good blocklist is available, no servers will be reported as blocked until it is retrieved")
+
+		return
+	}
+
+	SetBlockedServerList(newest.Hashes, newest.UpdatedAt)
+
+	slog.Info("Loaded last good EULA blocked servers", "size", len(newest.Hashes), "updated_at", newest.UpdatedAt)
+}
+
+// loadPersistedBlockedServerList returns the newest of the last good lists persisted in Redis and the local file, or nil
+// if neither has been persisted.
+func loadPersistedBlockedServerList() *persistedBlockedServerList {
+	var candidates []*persistedBlockedServerList
+
+	if data, _, err := r.Get(blocklistCacheKey); err != nil {
+		slog.Warn("Failed to read blocklist from Redis", "error", err)
+	} else if data != nil {
+		candidates = append(candidates, decodePersistedBlockedServerList(data, "redis"))
+	}
+
+	if len(config.Blocklist.CacheFile) > 0 {
+		if data, err := os.ReadFile(config.Blocklist.CacheFile); err == nil {
+			candidates = append(candidates, decodePersistedBlockedServerList(data, "file"))
+		} else if !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("Failed to read blocklist from file", "error", err, "file", config.Blocklist.CacheFile)
+		}
+	}
+
+	var newest *persistedBlockedServerList = nil
+
+	for _, candidate := range candidates {
+		if candidate != nil && (newest == nil || candidate.UpdatedAt.After(newest.UpdatedAt)) {
+			newest = candidate
+		}
+	}
+
+	return newest
+}
+
+// DiffBlockedServerLists returns the hashes that are in the current list but not the previous one, and the hashes that
+// are in the previous list but not the current one.
+func DiffBlockedServerLists(previous, current []string) ([]string, []string) {
+	var (
+		previousSet *HashSet[string] = NewHashSet(previous)
+		currentSet  *HashSet[string] = NewHashSet(current)
+		added       []string         = make([]string, 0)
+		removed     []string         = make([]string, 0)
+	)
+
+	for _, hash := range current {
+		if !previousSet.Has(hash) {
+			added = append(added, hash)
+		}
+	}
+
+	for _, hash := range previous {
+		if !currentSet.Has(hash) {
+			removed = append(removed, hash)
+		}
+	}
+
+	return added, removed
+}
+
+// RecordBlocklistChanges stores every hash that was added to or removed from the blocked servers list, along with any
+// servers that are known to match it, and delivers them to the webhooks subscribed to blocklist changes.
+func RecordBlocklistChanges(previous, current []string, timestamp time.Time) error {
+	added, removed := DiffBlockedServerLists(previous, current)
+
+	if len(added) < 1 && len(removed) < 1 {
+		return nil
+	}
+
+	servers, err := GetKnownBlocklistServers(append(append([]string{}, added...), removed...))
+
+	if err != nil {
+		return err
+	}
+
+	changes := make([]BlocklistChange, 0, len(added)+len(removed))
+
+	for _, group := range []struct {
+		Type   string
+		Hashes []string
+	}{{BlocklistChangeAdded, added}, {BlocklistChangeRemoved, removed}} {
+		for _, hash := range group.Hashes {
+			change := BlocklistChange{
+				ID:        RandomHexString(16),
+				Type:      group.Type,
+				Hash:      hash,
+				Pattern:   nil,
+				Servers:   servers[hash],
+				Timestamp: timestamp,
+			}
+
+			if pattern, ok := blocklistDictionary[hash]; ok {
+				change.Pattern = PointerOf(pattern)
+			}
+
+			if change.Servers == nil {
+				change.Servers = make([]string, 0)
+			}
+
+			changes = append(changes, change)
+		}
+	}
+
+	if err = db.InsertBlocklistChanges(changes); err != nil {
+		return err
+	}
+
+	slog.Info("Recorded EULA blocked servers changes", "added", len(added), "removed", len(removed))
+
+	if config.Webhooks.Enable {
+		go DeliverBlocklistChanges(changes)
+	}
+
+	return nil
+}
+
+// RecordKnownBlocklistServer stores the address of a Java Edition server requested from this API under the hash of each
+// pattern it is checked against, so that the servers matching a changed hash can be found without scanning every key.
+func RecordKnownBlocklistServer(ctx context.Context, hostname string, port uint16) error {
+	address := fmt.Sprintf("%s:%d", hostname, port)
+	hashes := Map(GetBlocklistPatterns(hostname), SHA256)
+
+	sets := map[string][]string{
+		"blocklist-known-hashes": hashes,
+	}
+
+	for _, hash := range hashes {
+		sets[fmt.Sprintf("blocklist-servers:%s", hash)] = []string{address}
+	}
+
+	return r.WithContext(ctx).SetAdd(sets)
+}
+
+// BackfillKnownBlocklistServers records every Java Edition server with a hit counter as a known server, so that the
+// servers requested before known servers were recorded on each request can also be found. The backfill only runs once
+// across all instances, and is retried at the next startup if it fails.
+func BackfillKnownBlocklistServers() error {
+	mutex := r.NewMutex("blocklist-backfill-lock")
+
+	ok, err := mutex.TryLock()
+
+	if err != nil || !ok {
+		return err
+	}
+
+	defer mutex.KeepAlive()()
+
+	done, _, err := r.Get("blocklist-known-backfilled")
+
+	if err != nil || done != nil {
+		return err
+	}
+
+	count := 0
+
+	err = r.Scan("java-hits:*", func(keys []string) error {
+		sets := make(map[string][]string)
+
+		for _, key := range keys {
+			address := strings.TrimPrefix(key, "java-hits:")
+
+			separator := strings.LastIndex(address, ":")
+
+			if separator < 0 {
+				continue
+			}
+
+			hashes := Map(GetBlocklistPatterns(address[:separator]), SHA256)
+
+			sets["blocklist-known-hashes"] = append(sets["blocklist-known-hashes"], hashes...)
+
+			for _, hash := range hashes {
+				setKey := fmt.Sprintf("blocklist-servers:%s", hash)
+
+				sets[setKey] = append(sets[setKey], address)
+			}
+
+			count++
+		}
+
+		return r.SetAdd(sets)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	slog.Info("Backfilled known EULA blocked servers", "servers", count)
+
+	return r.Set("blocklist-known-backfilled", time.Now().UTC().Format(time.RFC3339), 0)
+}
+
+// GetKnownBlocklistServers returns the Java Edition servers that have previously been requested from this API which
+// match any of the hashes, keyed by hash.
+func GetKnownBlocklistServers(hashes []string) (map[string][]string, error) {
+	result := make(map[string][]string)
+
+	known, err := r.SetIsMember("blocklist-known-hashes", hashes...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	knownHashes := make([]string, 0)
+
+	for i, hash := range hashes {
+		if known[i] {
+			knownHashes = append(knownHashes, hash)
+		}
+	}
+
+	servers, err := r.SetMembers(Map(knownHashes, func(v string) string { return fmt.Sprintf("blocklist-servers:%s", v) })...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for i, hash := range knownHashes {
+		if len(servers[i]) > 0 {
+			result[hash] = servers[i]
+		}
+	}
+
+	return result, nil
+}
+
+// StartBlocklistRefresh refreshes the blocked servers list in the background at the configured interval.
+func StartBlocklistRefresh() {
+	if config.Blocklist.RefreshInterval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(config.Blocklist.RefreshInterval)
+
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := RefreshBlockedServerList(); err != nil {
+				slog.Error("Failed to refresh EULA blocked servers", "error", err)
+			}
+		}
+	}()
+}
+
+// FindBlocklistMatch checks the hostname, the SRV target and each resolved IP address of a server against the blocked
+// servers list, the same as the vanilla client does before connecting, and returns the first one that is blocked.
+func FindBlocklistMatch(hostname string, srvTarget *string, ipAddresses []string) *BlocklistMatch {
+	list := blockedServers.Load()
+
+	if list == nil {
+		return nil
+	}
+
+	check := func(source, address string) *BlocklistMatch {
+		for _, pattern := range GetBlocklistPatterns(address) {
+			if list.Hashes.Has(SHA256(pattern)) {
+				return &BlocklistMatch{
+					Source:  source,
+					Address: address,
+					Pattern: pattern,
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if match := check(BlocklistSourceHostname, hostname); match != nil {
+		return match
+	}
+
+	if srvTarget != nil {
+		if match := check(BlocklistSourceSRVTarget, strings.ToLower(*srvTarget)); match != nil {
+			return match
+		}
+	}
+
+	for _, ipAddress := range ipAddresses {
+		if match := check(BlocklistSourceIPAddress, ipAddress); match != nil {
+			return match
+		}
+	}
+
+	return nil
+}
+
+// LoadBlocklistDictionary reads the known plaintext patterns of blocked servers list hashes from the configured file.
+// Each line is either a plaintext pattern, or a SHA-1 hash and its plaintext pattern separated by a colon.
+func LoadBlocklistDictionary() error {
+	if len(config.Blocklist.DictionaryFile) < 1 {
+		return nil
+	}
+
+	data, err := os.ReadFile(config.Blocklist.DictionaryFile)
+
+	if err != nil {
+		return err
+	}
+
+	result := make(map[string]string)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimSpace(line); len(line) < 1 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if hash, pattern, ok := strings.Cut(line, ":"); ok && sha1HashRegEx.MatchString(hash) {
+			result[strings.ToLower(hash)] = strings.ToLower(pattern)
+
+			continue
+		}
+
+		result[SHA256(strings.ToLower(line))] = strings.ToLower(line)
+	}
+
+	blocklistDictionary = result
+
+	return nil
+}
+
+// WriteFileAtomic writes the data to a temporary file and renames it over the destination, so that the file is never
+// left partially written.
+func WriteFileAtomic(file string, data []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(file), fmt.Sprintf(".%s-*", filepath.Base(file)))
+
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(temp.Name())
+
+	if _, err = temp.Write(data); err != nil {
+		temp.Close()
+
+		return err
+	}
+
+	if err = temp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(temp.Name(), file)
+}
+
+func decodePersistedBlockedServerList(data []byte, source string) *persistedBlockedServerList {
+	result := &persistedBlockedServerList{}
+
+	if err := json.Unmarshal(data, result); err != nil {
+		slog.Warn("Failed to decode persisted blocklist", "error", err, "source", source)
+
+		return nil
+	}
+
+	if len(result.Hashes) < 1 {
+		return nil
+	}
+
+	return result
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestBlockedHandlerKnownPatterns(t *testing.T) {
+	previousList, previousDictionary := blockedServers.Load(), blocklistDictionary
+
+	t.Cleanup(func() {
+		blockedServers.Store(previousList)
+		blocklistDictionary = previousDictionary
+	})
+
+	hash := SHA256("*.example.com")
+
+	SetBlockedServerList([]string{hash}, time.Now())
+
+	blocklistDictionary = map[string]string{hash: "*.example.com"}
+
+	app := fiber.New()
+	app.Get("/blocked/:address", BlockedHandler)
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/blocked/play.example.com", nil))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var body struct {
+		Blocked  bool               `json:"blocked"`
+		Matched  []string           `json:"matched"`
+		Patterns []BlocklistPattern `json:"patterns"`
+	}
+
+	if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+
+	if !body.Blocked || len(body.Matched) != 1 || body.Matched[0] != "*.example.com" {
+		t.Fatalf("unexpected match: %+v", body)
+	}
+
+	for _, pattern := range body.Patterns {
+		if pattern.Hash == hash {
+			if pattern.KnownPattern == nil || *pattern.KnownPattern != "*.example.com" {
+				t.Errorf("expected the known pattern of the matched hash, got %v", pattern.KnownPattern)
+			}
+		} else if pattern.KnownPattern != nil {
+			t.Errorf("expected no known pattern for %s, got %s", pattern.Pattern, *pattern.KnownPattern)
+		}
+	}
+}
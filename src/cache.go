@@ -0,0 +1,398 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+const cacheWaitInterval = 100 * time.Millisecond
+
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil)
+	zstdDecoder, _ = zstd.NewReader(nil)
+)
+
+// CacheStatus describes whether a response was served from the cache, and how fresh it was.
+type CacheStatus struct {
+	Hit           bool
+	Stale         bool
+	TimeRemaining time.Duration
+}
+
+// CacheResult is a value along with the state of the cache it was retrieved from.
+type CacheResult[T any] struct {
+	Value  T
+	Status *CacheStatus
+}
+
+// StatusCacheEntry is the value cached for the status of a server. The statuses are stored already encoded as JSON so
+// they can be sent without encoding them again. Java Edition entries hold the status both with and without the data
+// retrieved using query, so a single entry can satisfy requests with either option.
+type StatusCacheEntry struct {
+	Query       bool   `msgpack:"query"`
+	RetrievedAt int64  `msgpack:"retrieved_at"`
+	Full        []byte `msgpack:"full"`
+	Basic       []byte `msgpack:"basic"`
+}
+
+// Response returns the JSON encoded status matching the query option, which falls back to the status without query data.
+func (e *StatusCacheEntry) Response(query bool) []byte {
+	if query && e.Full != nil {
+		return e.Full
+	}
+
+	return e.Basic
+}
+
+// CacheEntryInfo is the information about a single cache entry belonging to a server.
+type CacheEntryInfo struct {
+	Key  string   `json:"key"`
+	Type string   `json:"type"`
+	TTL  *float64 `json:"ttl"`
+	Size int64    `json:"size"`
+}
+
+// GetCacheEntryInfo returns the cache entries of the server that currently exist, including locks.
+func GetCacheEntryInfo(edition, hostname string, port uint16) ([]CacheEntryInfo, error) {
+	cacheKey := GetCacheKey(hostname, port)
+
+	entries := []CacheEntryInfo{
+		{Key: fmt.Sprintf("%s:%s", edition, cacheKey), Type: "status"},
+		{Key: fmt.Sprintf("%s-lock:%s", edition, cacheKey), Type: "lock"},
+		{Key: fmt.Sprintf("%s-offline:%s", edition, cacheKey), Type: "offline_count"},
+		{Key: fmt.Sprintf("refresh:%s:%s", edition, cacheKey), Type: "refresh"},
+	}
+
+	switch edition {
+	case "java":
+		entries = append(
+			entries,
+			CacheEntryInfo{Key: fmt.Sprintf("icon:%s", cacheKey), Type: "icon"},
+			CacheEntryInfo{Key: fmt.Sprintf("refresh:icon:%s", cacheKey), Type: "refresh"},
+		)
+	case "bedrock":
+		break
+	default:
+		return nil, fmt.Errorf("unknown edition: %s", edition)
+	}
+
+	ttls, sizes, err := r.Inspect(Map(entries, func(v CacheEntryInfo) string { return v.Key })...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]CacheEntryInfo, 0)
+
+	for i, entry := range entries {
+		if ttls == nil || ttls[i] == -2 {
+			continue
+		}
+
+		if ttls[i] >= 0 {
+			entry.TTL = PointerOf(ttls[i].Seconds())
+		}
+
+		entry.Size = sizes[i]
+
+		result = append(result, entry)
+	}
+
+	return result, nil
+}
+
+// GetCacheEntry retrieves a cached value, and marks it as stale if it has expired but is still within the grace window.
+func GetCacheEntry(ctx context.Context, key string, staleDuration time.Duration) ([]byte, *CacheStatus, error) {
+	data, ttl, err := r.WithContext(ctx).Get(key)
+
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+
+	if ttl > staleDuration {
+		return data, &CacheStatus{Hit: true, Stale: false, TimeRemaining: ttl - staleDuration}, nil
+	}
+
+	return data, &CacheStatus{Hit: true, Stale: true, TimeRemaining: 0}, nil
+}
+
+// GetCachedStatus returns the cached status entry, or nil if it is not in the cache, cannot be decoded, is older than the
+// requested max age, or does not contain the query data requested.
+func GetCachedStatus(key string, staleDuration time.Duration, opts *StatusOptions) (*StatusCacheEntry, *CacheStatus, error) {
+	data, cacheStatus, err := GetCacheEntry(StatusContext(opts), key, staleDuration)
+
+	if err != nil || data == nil {
+		return nil, nil, err
+	}
+
+	entry, err := DecodeStatusCacheEntry(data)
+
+	if err != nil {
+		slog.WarnContext(StatusContext(opts), "Failed to decode cache entry, ignoring it", "error", err, "key", key)
+
+		return nil, nil, nil
+	}
+
+	if (opts.Query && !entry.Query) || !IsWithinMaxAge(entry.RetrievedAt, opts) {
+		return nil, nil, nil
+	}
+
+	return entry, cacheStatus, nil
+}
+
+// SetCachedStatus encodes the status entry and puts it into the cache.
+func SetCachedStatus(key string, entry *StatusCacheEntry, duration, staleDuration time.Duration) error {
+	data, err := EncodeStatusCacheEntry(entry)
+
+	if err != nil {
+		return err
+	}
+
+	return SetCacheEntry(key, data, duration, staleDuration)
+}
+
+// EncodeStatusCacheEntry encodes the status entry using MessagePack and compresses it using Zstandard.
+func EncodeStatusCacheEntry(entry *StatusCacheEntry) ([]byte, error) {
+	data, err := msgpack.Marshal(entry)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return zstdEncoder.EncodeAll(data, make([]byte, 0, len(data))), nil
+}
+
+// DecodeStatusCacheEntry decompresses and decodes the status entry encoded using EncodeStatusCacheEntry.
+func DecodeStatusCacheEntry(data []byte) (*StatusCacheEntry, error) {
+	decompressed, err := zstdDecoder.DecodeAll(data, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var entry StatusCacheEntry
+
+	if err = msgpack.Unmarshal(decompressed, &entry); err != nil {
+		return nil, err
+	}
+
+	return &entry, nil
+}
+
+// SetCacheEntry puts the value into the cache, keeping it around for the grace window after it expires.
+func SetCacheEntry(key string, value interface{}, duration, staleDuration time.Duration) error {
+	return r.Set(key, value, duration+staleDuration)
+}
+
+// IsWithinMaxAge returns whether a status retrieved at the given Unix millisecond timestamp satisfies the requested max age.
+func IsWithinMaxAge(retrievedAt int64, opts *StatusOptions) bool {
+	return opts.MaxAge == nil || time.Since(time.UnixMilli(retrievedAt)) <= *opts.MaxAge
+}
+
+// GetStatusCacheDuration returns how long a status should be cached for. The number of consecutive offline probes is
+// tracked using the counter key, and the duration of offline statuses backs off exponentially past the threshold.
+func GetStatusCacheDuration(counterKey string, online bool, onlineDuration, offlineDuration time.Duration) (time.Duration, error) {
+	if online {
+		return onlineDuration, r.Delete(counterKey)
+	}
+
+	var (
+		maxDuration time.Duration = config.Cache.OfflineBackoffMaxDuration
+		threshold   int64         = config.Cache.OfflineBackoffThreshold
+		counterTTL  time.Duration = maxDuration * 2
+	)
+
+	if maxDuration <= offlineDuration {
+		return offlineDuration, nil
+	}
+
+	// The counter stops increasing once the maximum duration is reached, as further offline probes cannot change it
+	maxCount := GetOfflineBackoffMaxCount(threshold, offlineDuration, maxDuration)
+
+	value, _, err := r.Get(counterKey)
+
+	if err != nil {
+		return 0, err
+	}
+
+	if count, err := strconv.ParseInt(string(value), 10, 64); err == nil && count >= maxCount {
+		return maxDuration, r.Expire(counterKey, counterTTL)
+	}
+
+	count, err := r.IncrementWithTTL(counterKey, counterTTL)
+
+	if err != nil {
+		return 0, err
+	}
+
+	return GetOfflineBackoffDuration(count, threshold, offlineDuration, maxDuration), nil
+}
+
+// GetOfflineBackoffDuration returns how long an offline status should be cached for after the number of consecutive
+// offline probes, doubling the offline duration for every probe past the threshold up to the maximum duration.
+func GetOfflineBackoffDuration(count, threshold int64, offlineDuration, maxDuration time.Duration) time.Duration {
+	exponent := count - threshold
+
+	if exponent <= 0 {
+		return offlineDuration
+	}
+
+	// Shifting past the maximum would overflow, so the maximum is returned before the shift is made
+	if exponent >= 63 || offlineDuration > maxDuration>>exponent {
+		return maxDuration
+	}
+
+	return min(offlineDuration<<exponent, maxDuration)
+}
+
+// GetOfflineBackoffMaxCount returns the number of consecutive offline probes at which the maximum duration is reached.
+func GetOfflineBackoffMaxCount(threshold int64, offlineDuration, maxDuration time.Duration) int64 {
+	exponent := int64(1)
+
+	for exponent < 63 && GetOfflineBackoffDuration(threshold+exponent, threshold, offlineDuration, maxDuration) < maxDuration {
+		exponent++
+	}
+
+	return threshold + exponent
+}
+
+// RevalidateInBackground runs the refresh function in the background, unless any process is already refreshing the same key.
+func RevalidateInBackground(key string, timeout time.Duration, refresh func() error) {
+	go func() {
+		refreshKey := fmt.Sprintf("refresh:%s", key)
+
+		acquired, err := r.SetNX(refreshKey, instanceID, timeout+time.Second*5)
+
+		if err != nil {
+			slog.Error("Failed to acquire refresh key", "error", err, "key", key)
+
+			return
+		}
+
+		if !acquired {
+			return
+		}
+
+		defer r.Delete(refreshKey)
+
+		if err = refresh(); err != nil {
+			slog.Error("Failed to refresh cache entry", "error", err, "key", key)
+		}
+	}()
+}
+
+// Coalesce runs the function only once at a time for the key on this instance, sharing the result with any identical
+// requests made while it is running.
+func Coalesce[T any](group *singleflight.Group, key string, fn func() (T, error)) (T, error) {
+	result, err, _ := group.Do(key, func() (interface{}, error) {
+		return fn()
+	})
+
+	if err != nil {
+		var empty T
+
+		return empty, err
+	}
+
+	return result.(T), nil
+}
+
+// FetchExclusive runs the fetch function while holding the lock, so only one process fetches the same value. If another
+// process holds the lock, the lookup function is used to wait for the result it puts into the cache instead.
+func FetchExclusive[T any](lockKey string, opts *StatusOptions, lookup func() (T, *CacheStatus, error), fetch func() (T, error)) (*CacheResult[T], error) {
+	if config.Cache.EnableLocks {
+		ctx, span := StartSpan(StatusContext(opts), "lock wait", attribute.String("lock.key", lockKey))
+		mutex := r.WithContext(ctx).NewMutex(lockKey)
+		start := time.Now()
+
+		// endWait records the time spent on the lock before either fetching or using the value cached by the holder
+		endWait := func(outcome string, err error) {
+			ObserveLockWait(lockKey, outcome, start)
+
+			span.SetAttributes(attribute.String("lock.outcome", outcome))
+
+			EndSpan(span, err)
+		}
+
+		acquired, err := mutex.TryLock()
+
+		if err != nil {
+			endWait("error", err)
+
+			slog.WarnContext(ctx, "Failed to obtain lock, fetching without it", "error", err, "key", lockKey)
+		} else if acquired {
+			endWait("acquired", nil)
+
+			release := mutex.KeepAlive()
+
+			defer release()
+
+			// The process that previously held the lock may have already cached the result
+			if !opts.BypassCache {
+				value, cacheStatus, err := lookup()
+
+				if err != nil {
+					return nil, err
+				}
+
+				if cacheStatus != nil {
+					return &CacheResult[T]{Value: value, Status: cacheStatus}, nil
+				}
+			}
+		} else if !opts.BypassCache {
+			value, cacheStatus, err := WaitForCache(opts.Timeout+time.Second, lookup)
+
+			endWait("waited", err)
+
+			if err != nil {
+				return nil, err
+			}
+
+			if cacheStatus != nil {
+				return &CacheResult[T]{Value: value, Status: cacheStatus}, nil
+			}
+
+			slog.WarnContext(ctx, "Timed out waiting for lock holder, fetching without lock", "key", lockKey)
+		}
+	}
+
+	value, err := fetch()
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &CacheResult[T]{Value: value, Status: &CacheStatus{}}, nil
+}
+
+// WaitForCache repeatedly calls the lookup function until it finds a cached value, or the timeout is reached.
+func WaitForCache[T any](timeout time.Duration, lookup func() (T, *CacheStatus, error)) (T, *CacheStatus, error) {
+	ticker := time.NewTicker(cacheWaitInterval)
+
+	defer ticker.Stop()
+
+	deadline := time.After(timeout)
+
+	for {
+		select {
+		case <-deadline:
+			var empty T
+
+			return empty, nil, nil
+		case <-ticker.C:
+			value, cacheStatus, err := lookup()
+
+			if err != nil || cacheStatus != nil {
+				return value, cacheStatus, err
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGetOfflineBackoffDuration(t *testing.T) {
+	const maxDuration = time.Minute * 30
+
+	tests := []struct {
+		name            string
+		count           int64
+		offlineDuration time.Duration
+		expected        time.Duration
+	}{
+		{"below threshold", 3, time.Second * 15, time.Second * 15},
+		{"at threshold", 5, time.Second * 15, time.Second * 15},
+		{"first backoff", 6, time.Second * 15, time.Second * 30},
+		{"second backoff", 7, time.Second * 15, time.Minute},
+		{"reaches maximum", 13, time.Second * 15, maxDuration},
+		{"exponent 30", 35, time.Second * 15, maxDuration},
+		{"exponent 31", 36, time.Second * 15, maxDuration},
+		{"exponent 62", 67, time.Second * 15, maxDuration},
+		{"exponent 63", 68, time.Second * 15, maxDuration},
+		{"exponent past 63", 1000, time.Second * 15, maxDuration},
+		{"maximum count", 1 << 62, time.Second * 15, maxDuration},
+		{"one nanosecond", 46, time.Nanosecond, maxDuration},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := GetOfflineBackoffDuration(test.count, 5, test.offlineDuration, maxDuration)
+
+			if result != test.expected {
+				t.Errorf("expected %s, got %s", test.expected, result)
+			}
+
+			if result <= 0 || result > maxDuration {
+				t.Errorf("duration %s is outside of (0, %s]", result, maxDuration)
+			}
+		})
+	}
+}
+
+func TestGetOfflineBackoffMaxCount(t *testing.T) {
+	tests := []struct {
+		offlineDuration time.Duration
+		maxDuration     time.Duration
+		expected        int64
+	}{
+		{time.Second * 15, time.Minute * 30, 12},
+		{time.Second * 15, time.Second * 30, 6},
+		{time.Second * 15, time.Second * 31, 7},
+		{time.Nanosecond, time.Hour, 47},
+	}
+
+	for _, test := range tests {
+		result := GetOfflineBackoffMaxCount(5, test.offlineDuration, test.maxDuration)
+
+		if result != test.expected {
+			t.Errorf("%s to %s: expected %d, got %d", test.offlineDuration, test.maxDuration, test.expected, result)
+		}
+
+		if GetOfflineBackoffDuration(result, 5, test.offlineDuration, test.maxDuration) != test.maxDuration {
+			t.Errorf("%s to %s: maximum duration is not reached at count %d", test.offlineDuration, test.maxDuration, result)
+		}
+
+		if GetOfflineBackoffDuration(result-1, 5, test.offlineDuration, test.maxDuration) >= test.maxDuration {
+			t.Errorf("%s to %s: maximum duration is reached before count %d", test.offlineDuration, test.maxDuration, result)
+		}
+	}
+}
+
+func TestStatusOptionsCoalesceKey(t *testing.T) {
+	base := StatusOptions{Timeout: time.Second * 5}
+
+	variants := map[string]StatusOptions{
+		"query":        {Timeout: time.Second * 5, Query: true},
+		"bypass cache": {Timeout: time.Second * 5, BypassCache: true},
+		"max age":      {Timeout: time.Second * 5, MaxAge: PointerOf(time.Second * 10)},
+		"timeout":      {Timeout: time.Second},
+		"disable srv":  {Timeout: time.Second * 5, DisableSRV: true},
+	}
+
+	for name, variant := range variants {
+		if base.CoalesceKey("key") == variant.CoalesceKey("key") {
+			t.Errorf("%s: options with different values share a key", name)
+		}
+	}
+
+	same := StatusOptions{Timeout: time.Second * 5, Trace: &ProbeTrace{}}
+
+	if base.CoalesceKey("key") != same.CoalesceKey("key") {
+		t.Errorf("options that do not change the fetch do not share a key")
+	}
+}
@@ -17,33 +17,269 @@ var (
 		Port:        3001,
 		MongoDB:     nil,
 		Redis:       nil,
+		AdminTokens: []string{},
+		Blocklist: ConfigBlocklist{
+			URL:             "https://sessionserver.mojang.com/blockedservers",
+			RefreshInterval: time.Hour,
+			Timeout:         time.Second * 10,
+			CacheFile:       "blocklist.json",
+			DictionaryFile:  "",
+		},
+		Health: ConfigHealth{
+			CriticalChecks:  []string{"redis", "mongodb", "blocklist"},
+			BlocklistMaxAge: time.Hour * 24,
+			ProbeCapacity:   512,
+		},
+		Logging: ConfigLogging{
+			Format:    "json",
+			Level:     "info",
+			AccessLog: true,
+		},
+		Metrics: ConfigMetrics{
+			Enable: true,
+			Tokens: []string{},
+		},
+		Tracing: ConfigTracing{
+			Enable:      false,
+			Exporter:    "otlp",
+			Endpoint:    "localhost:4318",
+			Insecure:    true,
+			ServiceName: "mcstatus-api",
+			SampleRatio: 1.0,
+		},
+		Stream: ConfigStream{
+			RefreshInterval:   time.Second * 10,
+			Timeout:           time.Second * 5,
+			HeartbeatInterval: time.Second * 15,
+			MaxPerClient:      10,
+			MaxPerAddress:     1000,
+		},
+		Probe: ConfigProbe{
+			QueryGracePeriod: time.Millisecond * 250,
+			Modules: map[string]ConfigProbeModule{
+				"default": {
+					Timeout: time.Second * 5,
+					Query:   false,
+					SRV:     true,
+				},
+			},
+		},
+		Monitor: ConfigMonitor{
+			Enable:            false,
+			ScanInterval:      time.Second * 5,
+			Concurrency:       32,
+			MaxJitter:         time.Second * 10,
+			MinimumInterval:   time.Second * 30,
+			MaxPerApplication: 100,
+			HistoryRetention:  time.Hour * 24 * 90,
+		},
+		Webhooks: ConfigWebhooks{
+			Enable:            false,
+			ScanInterval:      time.Second * 5,
+			Concurrency:       32,
+			MinimumInterval:   time.Second * 30,
+			MaxPerApplication: 25,
+			MaxAddresses:      10,
+			MaxAttempts:       5,
+			RetryBackoff:      time.Second * 2,
+			DeliveryTimeout:   time.Second * 10,
+			AllowPrivateURLs:  false,
+		},
+		RateLimit: ConfigRateLimit{
+			Enable:         true,
+			KeyBy:          "application",
+			Limit:          600,
+			AnonymousLimit: 60,
+			Window:         time.Minute,
+		},
+		Quotas: ConfigQuotas{
+			Enable:             true,
+			DefaultPlan:        "free",
+			UsageCacheDuration: time.Second * 10,
+		},
 		Cache: ConfigCache{
-			EnableLocks:           true,
-			JavaStatusDuration:    time.Minute,
-			BedrockStatusDuration: time.Minute,
-			IconDuration:          time.Minute * 15,
-			BypassTokens:          []string{},
+			EnableLocks:                  true,
+			JavaStatusDuration:           time.Minute,
+			JavaStatusOfflineDuration:    time.Second * 15,
+			JavaStatusStaleDuration:      time.Second * 30,
+			BedrockStatusDuration:        time.Minute,
+			BedrockStatusOfflineDuration: time.Second * 15,
+			BedrockStatusStaleDuration:   time.Second * 30,
+			IconDuration:                 time.Minute * 15,
+			IconStaleDuration:            time.Hour,
+			OfflineBackoffThreshold:      5,
+			OfflineBackoffMaxDuration:    time.Minute * 30,
+			BypassTokens:                 []string{},
+			MinimumMaxAge:                time.Second * 10,
+			TokenMinimumMaxAges:          map[string]time.Duration{},
 		},
 	}
 )
 
 // Config represents the application configuration.
 type Config struct {
-	Environment string      `yaml:"environment"`
-	Host        string      `yaml:"host"`
-	Port        uint16      `yaml:"port"`
-	MongoDB     *string     `yaml:"mongodb"`
-	Redis       *string     `yaml:"redis"`
-	Cache       ConfigCache `yaml:"cache"`
+	Environment string          `yaml:"environment"`
+	Host        string          `yaml:"host"`
+	Port        uint16          `yaml:"port"`
+	MongoDB     *string         `yaml:"mongodb"`
+	Redis       *string         `yaml:"redis"`
+	AdminTokens []string        `yaml:"admin_tokens"`
+	Blocklist   ConfigBlocklist `yaml:"blocklist"`
+	Health      ConfigHealth    `yaml:"health"`
+	Logging     ConfigLogging   `yaml:"logging"`
+	Metrics     ConfigMetrics   `yaml:"metrics"`
+	Tracing     ConfigTracing   `yaml:"tracing"`
+	Stream      ConfigStream    `yaml:"stream"`
+	Probe       ConfigProbe     `yaml:"probe"`
+	Monitor     ConfigMonitor   `yaml:"monitor"`
+	Webhooks    ConfigWebhooks  `yaml:"webhooks"`
+	RateLimit   ConfigRateLimit `yaml:"rate_limit"`
+	Quotas      ConfigQuotas    `yaml:"quotas"`
+	Cache       ConfigCache     `yaml:"cache"`
+}
+
+// ConfigMonitor represents the background polling of registered servers. The scan interval is how often each instance
+// checks for monitors that are due, and a random jitter up to the maximum is added to every poll interval.
+type ConfigMonitor struct {
+	Enable            bool          `yaml:"enable"`
+	ScanInterval      time.Duration `yaml:"scan_interval"`
+	Concurrency       int           `yaml:"concurrency"`
+	MaxJitter         time.Duration `yaml:"max_jitter"`
+	MinimumInterval   time.Duration `yaml:"minimum_interval"`
+	MaxPerApplication int64         `yaml:"max_per_application"`
+	HistoryRetention  time.Duration `yaml:"history_retention"`
+}
+
+// ConfigWebhooks represents the polling of addresses watched by webhooks and the delivery of their events. Failed
+// deliveries are retried up to the maximum attempts, waiting twice as long as the retry backoff after every attempt.
+// Deliveries to private, loopback and link-local addresses are refused unless private URLs are allowed.
+type ConfigWebhooks struct {
+	Enable            bool          `yaml:"enable"`
+	ScanInterval      time.Duration `yaml:"scan_interval"`
+	Concurrency       int           `yaml:"concurrency"`
+	MinimumInterval   time.Duration `yaml:"minimum_interval"`
+	MaxPerApplication int64         `yaml:"max_per_application"`
+	MaxAddresses      int           `yaml:"max_addresses"`
+	MaxAttempts       int           `yaml:"max_attempts"`
+	RetryBackoff      time.Duration `yaml:"retry_backoff"`
+	DeliveryTimeout   time.Duration `yaml:"delivery_timeout"`
+	AllowPrivateURLs  bool          `yaml:"allow_private_urls"`
+}
+
+// ConfigRateLimit represents the sliding window rate limit applied to every route. Authenticated requests are limited
+// per token or per application depending on the key, using the limit of the application if it has one, and all other
+// requests are limited per client IP address using the anonymous limit. Rate limiting requires Redis.
+type ConfigRateLimit struct {
+	Enable         bool          `yaml:"enable"`
+	KeyBy          string        `yaml:"key_by"`
+	Limit          int64         `yaml:"limit"`
+	AnonymousLimit int64         `yaml:"anonymous_limit"`
+	Window         time.Duration `yaml:"window"`
+}
+
+// ConfigQuotas represents the daily and monthly request quotas of applications, which are set by the plan of each
+// application, or the default plan if it does not have one. The usage of an application is kept in memory for the usage
+// cache duration between checks, so it may go over its quota by the requests made during that time.
+type ConfigQuotas struct {
+	Enable             bool          `yaml:"enable"`
+	DefaultPlan        string        `yaml:"default_plan"`
+	UsageCacheDuration time.Duration `yaml:"usage_cache_duration"`
+}
+
+// ConfigBlocklist represents the retrieval of the EULA blocked servers list. The last good list is persisted to Redis
+// and the cache file, which are used at startup if the list cannot be retrieved from the URL. The dictionary file contains
+// known plaintext patterns of hashes in the list, which are used to explain why an address is blocked.
+type ConfigBlocklist struct {
+	URL             string        `yaml:"url"`
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	Timeout         time.Duration `yaml:"timeout"`
+	CacheFile       string        `yaml:"cache_file"`
+	DictionaryFile  string        `yaml:"dictionary_file"`
+}
+
+// ConfigHealth represents the readiness checks. The instance is not ready if any of the critical checks fail, which
+// are any of `redis`, `mongodb`, `blocklist` and `probes`. The probe capacity is the number of status fetches in flight
+// at which the instance is considered saturated.
+type ConfigHealth struct {
+	CriticalChecks  []string      `yaml:"critical_checks"`
+	BlocklistMaxAge time.Duration `yaml:"blocklist_max_age"`
+	ProbeCapacity   int64         `yaml:"probe_capacity"`
+}
+
+// ConfigLogging represents the output of the logger. The format is either `json` or `text`, and the level is the lowest
+// level written, which is one of `debug`, `info`, `warn` or `error`.
+type ConfigLogging struct {
+	Format    string `yaml:"format"`
+	Level     string `yaml:"level"`
+	AccessLog bool   `yaml:"access_log"`
+}
+
+// ConfigMetrics represents the Prometheus metrics and probe routes. If any tokens are set, the routes can only be used
+// with one of the tokens in the Authorization header. The probe route is disabled until at least one token is set, as it
+// fetches a fresh status on every request.
+type ConfigMetrics struct {
+	Enable bool     `yaml:"enable"`
+	Tokens []string `yaml:"tokens"`
+}
+
+// ConfigTracing represents the export of OpenTelemetry spans. The exporter is either `otlp`, which sends spans over
+// OTLP/HTTP to the endpoint, or `stdout`, which prints them for local testing. The sample ratio is the fraction of
+// traces recorded, unless the client of a request has already decided whether its trace is sampled.
+type ConfigTracing struct {
+	Enable      bool    `yaml:"enable"`
+	Exporter    string  `yaml:"exporter"`
+	Endpoint    string  `yaml:"endpoint"`
+	Insecure    bool    `yaml:"insecure"`
+	ServiceName string  `yaml:"service_name"`
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// ConfigStream represents the live status streams. Only one instance refreshes the status of each streamed server at the
+// refresh interval, and the limits are the most connections allowed at once for a single token or IP address, and for a
+// single server across all clients.
+type ConfigStream struct {
+	RefreshInterval   time.Duration `yaml:"refresh_interval"`
+	Timeout           time.Duration `yaml:"timeout"`
+	HeartbeatInterval time.Duration `yaml:"heartbeat_interval"`
+	MaxPerClient      int64         `yaml:"max_per_client"`
+	MaxPerAddress     int64         `yaml:"max_per_address"`
+}
+
+// ConfigProbe represents the behavior of the probes used to retrieve the status of servers. The query grace period is
+// how long query is waited on after all status probes have finished. The modules are the named sets of options that
+// can be selected when probing a server through the Prometheus probe route.
+type ConfigProbe struct {
+	QueryGracePeriod time.Duration                `yaml:"query_grace_period"`
+	Modules          map[string]ConfigProbeModule `yaml:"modules"`
+}
+
+// ConfigProbeModule represents the options used for a single probe of a server through the Prometheus probe route.
+type ConfigProbeModule struct {
+	Timeout time.Duration `yaml:"timeout"`
+	Query   bool          `yaml:"query"`
+	SRV     bool          `yaml:"srv"`
 }
 
-// ConfigCache represents the caching durations of various responses.
+// ConfigCache represents the caching durations of various responses. The stale durations are the grace windows
+// after expiration where the cached entry is still served while it is being refreshed in the background. Offline
+// statuses are cached for exponentially longer durations, up to the maximum, once a server has been offline for
+// more consecutive probes than the backoff threshold. The minimum max ages are the lowest values allowed for the
+// `maxAge` query parameter, either by default or for specific tokens.
 type ConfigCache struct {
-	EnableLocks           bool          `yaml:"enable_locks"`
-	JavaStatusDuration    time.Duration `yaml:"java_status_duration"`
-	BedrockStatusDuration time.Duration `yaml:"bedrock_status_duration"`
-	IconDuration          time.Duration `yaml:"icon_duration"`
-	BypassTokens          []string      `yaml:"bypass_tokens"`
+	EnableLocks                  bool                     `yaml:"enable_locks"`
+	JavaStatusDuration           time.Duration            `yaml:"java_status_duration"`
+	JavaStatusOfflineDuration    time.Duration            `yaml:"java_status_offline_duration"`
+	JavaStatusStaleDuration      time.Duration            `yaml:"java_status_stale_duration"`
+	BedrockStatusDuration        time.Duration            `yaml:"bedrock_status_duration"`
+	BedrockStatusOfflineDuration time.Duration            `yaml:"bedrock_status_offline_duration"`
+	BedrockStatusStaleDuration   time.Duration            `yaml:"bedrock_status_stale_duration"`
+	IconDuration                 time.Duration            `yaml:"icon_duration"`
+	IconStaleDuration            time.Duration            `yaml:"icon_stale_duration"`
+	OfflineBackoffThreshold      int64                    `yaml:"offline_backoff_threshold"`
+	OfflineBackoffMaxDuration    time.Duration            `yaml:"offline_backoff_max_duration"`
+	BypassTokens                 []string                 `yaml:"bypass_tokens"`
+	MinimumMaxAge                time.Duration            `yaml:"minimum_max_age"`
+	TokenMinimumMaxAges          map[string]time.Duration `yaml:"token_minimum_max_ages"`
 }
 
 // ReadFile reads the configuration from the given file and overrides values using environment variables.
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProbeTrace is the duration of each phase of a probe, which is filled in by the probes while fetching a status if it
+// is set in the status options. The status phase covers connecting and retrieving the status, and the ping phase is the
+// round trip time of a ping on the same connection, which is only measured for Java Edition servers. If connect timing
+// is enabled, the TCP connect to the resolved address is timed on its own connection alongside the status probes.
+type ProbeTrace struct {
+	TimeConnect bool
+	Address     string
+	DNSLookup   time.Duration
+	Connect     time.Duration
+	Status      time.Duration
+	Ping        time.Duration
+}
+
+// ProbeTargetResult is the result of a single probe of a server through the Prometheus probe route.
+type ProbeTargetResult struct {
+	Online          bool
+	PlayersOnline   *int64
+	PlayersMax      *int64
+	ProtocolVersion *int64
+	Duration        time.Duration
+	Trace           ProbeTrace
+}
+
+// ProbeTarget fetches a fresh status of the server using the options of the module, bypassing the cache entirely.
+func ProbeTarget(edition, hostname string, port uint16, module ConfigProbeModule) (*ProbeTargetResult, error) {
+	result := &ProbeTargetResult{}
+
+	opts := &StatusOptions{
+		Query:       module.Query,
+		Timeout:     module.Timeout,
+		BypassCache: true,
+		DisableSRV:  !module.SRV,
+		Trace:       &result.Trace,
+	}
+
+	result.Trace.TimeConnect = true
+
+	start := time.Now()
+
+	switch edition {
+	case "java":
+		{
+			response, err := FetchJavaStatus(hostname, port, opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			result.Duration = time.Since(start)
+			result.Online = response.Online
+
+			if response.JavaStatus != nil {
+				result.PlayersOnline = response.Players.Online
+				result.PlayersMax = response.Players.Max
+
+				if response.Version != nil {
+					result.ProtocolVersion = PointerOf(response.Version.Protocol)
+				}
+			}
+		}
+	case "bedrock":
+		{
+			response, err := FetchBedrockStatus(hostname, port, opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			result.Duration = time.Since(start)
+			result.Online = response.Online
+
+			if response.BedrockStatus != nil {
+				if response.Players != nil {
+					result.PlayersOnline = response.Players.Online
+					result.PlayersMax = response.Players.Max
+				}
+
+				if response.Version != nil {
+					result.ProtocolVersion = response.Version.Protocol
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown edition: %s", edition)
+	}
+
+	return result, nil
+}
+
+// NewProbeRegistry returns a registry containing the gauges of a single probe result, in the same style as the
+// Prometheus blackbox exporter. Gauges without a value in the result are left out.
+func NewProbeRegistry(result *ProbeTargetResult) *prometheus.Registry {
+	registry := prometheus.NewRegistry()
+
+	newGauge := func(name, help string, value float64) {
+		gauge := prometheus.NewGauge(prometheus.GaugeOpts{Name: name, Help: help})
+		gauge.Set(value)
+
+		registry.MustRegister(gauge)
+	}
+
+	up := 0.0
+
+	if result.Online {
+		up = 1.0
+	}
+
+	newGauge("minecraft_up", "Whether the server responded to the status probe.", up)
+	newGauge("minecraft_probe_duration_seconds", "The duration of the entire probe.", result.Duration.Seconds())
+
+	if result.Trace.Ping > 0 {
+		newGauge("minecraft_latency_seconds", "The round trip time of a ping to the server.", result.Trace.Ping.Seconds())
+	}
+
+	if result.PlayersOnline != nil {
+		newGauge("minecraft_players_online", "The number of players online.", float64(*result.PlayersOnline))
+	}
+
+	if result.PlayersMax != nil {
+		newGauge("minecraft_players_max", "The maximum number of players allowed online.", float64(*result.PlayersMax))
+	}
+
+	if result.ProtocolVersion != nil {
+		newGauge("minecraft_protocol_version", "The protocol version reported by the server.", float64(*result.ProtocolVersion))
+	}
+
+	phases := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "minecraft_probe_phase_duration_seconds",
+		Help: "The duration of each phase of the probe.",
+	}, []string{"phase"})
+
+	phases.WithLabelValues("dns").Set(result.Trace.DNSLookup.Seconds())
+
+	if result.Trace.Connect > 0 {
+		phases.WithLabelValues("connect").Set(result.Trace.Connect.Seconds())
+	}
+
+	if result.Trace.Status > 0 {
+		phases.WithLabelValues("status").Set(result.Trace.Status.Seconds())
+	}
+
+	if result.Trace.Ping > 0 {
+		phases.WithLabelValues("ping").Set(result.Trace.Ping.Seconds())
+	}
+
+	registry.MustRegister(phases)
+
+	return registry
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewProbeRegistry(t *testing.T) {
+	families, err := NewProbeRegistry(&ProbeTargetResult{
+		Online:        true,
+		PlayersOnline: PointerOf(int64(12)),
+		Duration:      time.Millisecond * 120,
+		Trace: ProbeTrace{
+			DNSLookup: time.Millisecond * 10,
+			Connect:   time.Millisecond * 15,
+			Status:    time.Millisecond * 80,
+			Ping:      time.Millisecond * 30,
+		},
+	}).Gather()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	values := make(map[string]float64)
+
+	for _, family := range families {
+		for _, metric := range family.GetMetric() {
+			name := family.GetName()
+
+			for _, label := range metric.GetLabel() {
+				name += ":" + label.GetValue()
+			}
+
+			values[name] = metric.GetGauge().GetValue()
+		}
+	}
+
+	expected := map[string]float64{
+		"minecraft_up":                                   1,
+		"minecraft_probe_duration_seconds":               0.12,
+		"minecraft_latency_seconds":                      0.03,
+		"minecraft_players_online":                       12,
+		"minecraft_probe_phase_duration_seconds:dns":     0.01,
+		"minecraft_probe_phase_duration_seconds:connect": 0.015,
+		"minecraft_probe_phase_duration_seconds:status":  0.08,
+		"minecraft_probe_phase_duration_seconds:ping":    0.03,
+	}
+
+	for name, value := range expected {
+		if actual, ok := values[name]; !ok || actual != value {
+			t.Errorf("expected %s to be %v, got %v (present: %t)", name, value, actual, ok)
+		}
+	}
+
+	if _, ok := values["minecraft_players_max"]; ok {
+		t.Error("expected gauges without a value to be left out")
+	}
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	HealthStatusOK       = "ok"
+	HealthStatusDegraded = "degraded"
+	HealthStatusFail     = "fail"
+	HealthStatusSkipped  = "skipped"
+)
+
+var (
+	// errHealthCheckSkipped is returned by checks of dependencies that are not configured.
+	errHealthCheckSkipped error = errors.New("not configured")
+	// inFlightProbes is the number of status fetches currently being made by this instance.
+	inFlightProbes atomic.Int64
+	healthChecks   map[string]func() error = map[string]func() error{
+		"redis": func() error {
+			if config.Redis == nil {
+				return errHealthCheckSkipped
+			}
+
+			return r.Ping()
+		},
+		"mongodb": func() error {
+			if config.MongoDB == nil {
+				return errHealthCheckSkipped
+			}
+
+			return db.Ping()
+		},
+		"blocklist": func() error {
+			list := blockedServers.Load()
+
+			if list == nil {
+				return fmt.Errorf("blocklist has never been retrieved")
+			}
+
+			if age := time.Since(list.UpdatedAt); age > config.Health.BlocklistMaxAge {
+				return fmt.Errorf("blocklist is %s old", age.Truncate(time.Second))
+			}
+
+			return nil
+		},
+		"probes": func() error {
+			if count := inFlightProbes.Load(); count >= config.Health.ProbeCapacity {
+				return fmt.Errorf("%d probes in flight, capacity is %d", count, config.Health.ProbeCapacity)
+			}
+
+			return nil
+		},
+	}
+)
+
+// HealthCheckResult is the result of a single readiness check.
+type HealthCheckResult struct {
+	Status   string  `json:"status"`
+	Critical bool    `json:"critical"`
+	Latency  float64 `json:"latency"`
+	Error    *string `json:"error"`
+}
+
+// HealthReport is the combined result of all readiness checks. The status is failed if any critical check failed, and
+// degraded if only non-critical checks failed.
+type HealthReport struct {
+	Status string                       `json:"status"`
+	Checks map[string]HealthCheckResult `json:"checks"`
+}
+
+// RunHealthChecks runs all readiness checks concurrently and returns their combined result.
+func RunHealthChecks() *HealthReport {
+	var (
+		result *HealthReport = &HealthReport{Status: HealthStatusOK, Checks: make(map[string]HealthCheckResult)}
+		mutex  sync.Mutex
+		wg     sync.WaitGroup
+	)
+
+	for name, check := range healthChecks {
+		wg.Add(1)
+
+		go func(name string, check func() error) {
+			defer wg.Done()
+
+			start := time.Now()
+
+			err := check()
+
+			checkResult := HealthCheckResult{
+				Status:   HealthStatusOK,
+				Critical: Contains(config.Health.CriticalChecks, name),
+				Latency:  float64(time.Since(start).Microseconds()) / 1000,
+				Error:    nil,
+			}
+
+			mutex.Lock()
+
+			defer mutex.Unlock()
+
+			if errors.Is(err, errHealthCheckSkipped) {
+				checkResult.Status = HealthStatusSkipped
+			} else if err != nil {
+				checkResult.Status = HealthStatusFail
+				checkResult.Error = PointerOf(err.Error())
+
+				if checkResult.Critical {
+					result.Status = HealthStatusFail
+				} else if result.Status == HealthStatusOK {
+					result.Status = HealthStatusDegraded
+				}
+			}
+
+			result.Checks[name] = checkResult
+		}(name, check)
+	}
+
+	wg.Wait()
+
+	return result
+}
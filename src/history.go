@@ -0,0 +1,99 @@
+package main
+
+import (
+	"time"
+)
+
+const (
+	maxHistorySamples = 10000
+	maxOutageSamples  = 100000
+)
+
+var (
+	historyResolutions map[string]HistoryResolution = map[string]HistoryResolution{
+		"5m": {Unit: "minute", BinSize: 5},
+		"1h": {Unit: "hour", BinSize: 1},
+		"1d": {Unit: "day", BinSize: 1},
+	}
+	uptimeWindows []UptimeWindow = []UptimeWindow{
+		{Name: "24h", Duration: time.Hour * 24},
+		{Name: "7d", Duration: time.Hour * 24 * 7},
+		{Name: "30d", Duration: time.Hour * 24 * 30},
+	}
+)
+
+// HistoryResolution is the unit and bin size used to downsample the status history.
+type HistoryResolution struct {
+	Unit    string
+	BinSize int
+}
+
+// UptimeWindow is a period of time before now that the uptime percentage is calculated for.
+type UptimeWindow struct {
+	Name     string
+	Duration time.Duration
+}
+
+// Outage is an interval of time where a server was offline. The end is nil if the server is still offline.
+type Outage struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end"`
+	Duration float64    `json:"duration"`
+}
+
+// GetUptime returns the percentage of samples recorded by the monitor where the server was online for each of the
+// uptime windows, or nil for any window without samples.
+func GetUptime(monitor string, now time.Time) (map[string]*float64, error) {
+	since := Map(uptimeWindows, func(v UptimeWindow) time.Time { return now.Add(-v.Duration) })
+
+	counts, err := db.GetUptimeCounts(monitor, since)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string]*float64)
+
+	for i, window := range uptimeWindows {
+		if counts[i].Samples < 1 {
+			result[window.Name] = nil
+
+			continue
+		}
+
+		result[window.Name] = PointerOf(float64(counts[i].OnlineSamples) / float64(counts[i].Samples) * 100)
+	}
+
+	return result, nil
+}
+
+// GetOutages returns the intervals where the server was offline, which start at the first offline sample and end at
+// the next online sample. The duration of an outage that has not ended is measured until the end of the range.
+func GetOutages(samples []StatusSample, end time.Time) []Outage {
+	result := make([]Outage, 0)
+
+	var current *Outage = nil
+
+	for _, sample := range samples {
+		if !sample.Online && current == nil {
+			current = &Outage{
+				Start: sample.Timestamp,
+			}
+		} else if sample.Online && current != nil {
+			current.End = PointerOf(sample.Timestamp)
+			current.Duration = sample.Timestamp.Sub(current.Start).Seconds()
+
+			result = append(result, *current)
+
+			current = nil
+		}
+	}
+
+	if current != nil {
+		current.Duration = end.Sub(current.Start).Seconds()
+
+		result = append(result, *current)
+	}
+
+	return result
+}
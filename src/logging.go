@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+var requestIDRegEx *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9-_.:]{1,128}$`)
+
+// requestContextKey is the key of the request details in the user context of a request.
+type requestContextKey struct{}
+
+// RequestDetails is the information about a request that is attached to every log line written while handling it.
+type RequestDetails struct {
+	ID             string
+	probeDurations map[string]time.Duration
+	mutex          sync.Mutex
+}
+
+// AddProbeDuration records the duration of a probe made while handling the request.
+func (d *RequestDetails) AddProbeDuration(probe string, duration time.Duration) {
+	d.mutex.Lock()
+
+	defer d.mutex.Unlock()
+
+	d.probeDurations[probe] += duration
+}
+
+// ProbeDurations returns the total duration of each probe made while handling the request, in milliseconds.
+func (d *RequestDetails) ProbeDurations() map[string]int64 {
+	d.mutex.Lock()
+
+	defer d.mutex.Unlock()
+
+	result := make(map[string]int64)
+
+	for probe, duration := range d.probeDurations {
+		result[probe] = duration.Milliseconds()
+	}
+
+	return result
+}
+
+// GetRequestDetails returns the details of the request that the context belongs to, or nil if it does not belong to one.
+func GetRequestDetails(ctx context.Context) *RequestDetails {
+	if ctx == nil {
+		return nil
+	}
+
+	details, ok := ctx.Value(requestContextKey{}).(*RequestDetails)
+
+	if !ok {
+		return nil
+	}
+
+	return details
+}
+
+// requestLogHandler is a log handler that adds the ID of the request to every log line written with its context.
+type requestLogHandler struct {
+	slog.Handler
+}
+
+func (h requestLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	if details := GetRequestDetails(ctx); details != nil {
+		record.AddAttrs(slog.String("request_id", details.ID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h requestLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return requestLogHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h requestLogHandler) WithGroup(name string) slog.Handler {
+	return requestLogHandler{h.Handler.WithGroup(name)}
+}
+
+// SetupLogging replaces the default logger with one using the format and level from the configuration.
+func SetupLogging() error {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(config.Logging.Level)); err != nil {
+		return fmt.Errorf("invalid log level: %s", config.Logging.Level)
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	var handler slog.Handler
+
+	switch strings.ToLower(config.Logging.Format) {
+	case "json":
+		handler = slog.NewJSONHandler(os.Stdout, opts)
+	case "text":
+		handler = slog.NewTextHandler(os.Stdout, opts)
+	default:
+		return fmt.Errorf("invalid log format: %s", config.Logging.Format)
+	}
+
+	slog.SetDefault(slog.New(requestLogHandler{handler}))
+
+	return nil
+}
+
+// Fatal logs the message as an error and exits the process.
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+
+	os.Exit(1)
+}
+
+// RequestMiddleware assigns an ID to every request, using the X-Request-ID header if the client sent a valid one, and
+// echoes it back in the response. Once the request has been handled, a line is written to the access log.
+func RequestMiddleware(ctx *fiber.Ctx) error {
+	start := time.Now()
+
+	// The header value points into the request buffer, which is reused once the request has been handled, so it is
+	// copied as the details outlive the request in background work and spans
+	details := &RequestDetails{
+		ID:             utils.CopyString(ctx.Get("X-Request-ID")),
+		probeDurations: make(map[string]time.Duration),
+	}
+
+	if !requestIDRegEx.MatchString(details.ID) {
+		details.ID = RandomHexString(16)
+	}
+
+	ctx.Set("X-Request-ID", details.ID)
+	ctx.SetUserContext(context.WithValue(ctx.UserContext(), requestContextKey{}, details))
+
+	if err := ctx.Next(); err != nil {
+		if err = ctx.App().ErrorHandler(ctx, err); err != nil {
+			_ = ctx.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	if !config.Logging.AccessLog {
+		return nil
+	}
+
+	attrs := []any{
+		slog.String("method", ctx.Method()),
+		slog.String("path", ctx.Path()),
+		slog.String("route", ctx.Route().Path),
+		slog.Int("status", ctx.Response().StatusCode()),
+		slog.Int64("duration_ms", time.Since(start).Milliseconds()),
+		slog.String("ip", ctx.IP()),
+	}
+
+	if token := GetRequestToken(ctx); token != nil {
+		attrs = append(attrs, slog.String("token", token.ID), slog.String("application", token.Application))
+	}
+
+	if cacheHit := ctx.GetRespHeader("X-Cache-Hit"); len(cacheHit) > 0 {
+		attrs = append(attrs, slog.Bool("cache_hit", cacheHit == "true"))
+	}
+
+	if probeDurations := details.ProbeDurations(); len(probeDurations) > 0 {
+		probes := make([]string, 0, len(probeDurations))
+
+		for probe := range probeDurations {
+			probes = append(probes, probe)
+		}
+
+		sort.Strings(probes)
+
+		attrs = append(attrs, slog.Group("probe_durations_ms", Map(probes, func(probe string) any {
+			return slog.Int64(probe, probeDurations[probe])
+		})...))
+	}
+
+	slog.InfoContext(ctx.UserContext(), "Request", attrs...)
+
+	return nil
+}
@@ -1,9 +1,10 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
 
@@ -20,7 +21,7 @@ var (
 				return ctx.SendStatus(fiberError.Code)
 			}
 
-			log.Printf("Error: %v - URI: %s\n", err, ctx.Request().URI())
+			slog.ErrorContext(ctx.UserContext(), "Failed to handle request", "error", err, "uri", ctx.Request().URI().String())
 
 			return ctx.SendStatus(http.StatusInternalServerError)
 		},
@@ -31,58 +32,97 @@ var (
 	instanceID uint16   = 0
 )
 
-func init() {
+// Setup reads the config file, connects to the configured services, loads the blocked servers list and registers the
+// routes of the application.
+func Setup() {
 	var err error
 
 	if err = config.ReadFile("config.yml"); err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			log.Printf("config.yml does not exist, writing default config\n")
+			slog.Info("config.yml does not exist, writing default config")
 
 			if err = config.WriteFile("config.yml"); err != nil {
-				log.Fatalf("Failed to write config file: %v", err)
+				Fatal("Failed to write config file", "error", err)
 			}
 		} else {
-			log.Printf("Failed to read config file: %v", err)
+			slog.Error("Failed to read config file", "error", err)
 		}
 	}
 
-	if err = GetBlockedServerList(); err != nil {
-		log.Fatalf("Failed to retrieve EULA blocked servers: %v", err)
+	if err = SetupLogging(); err != nil {
+		Fatal("Failed to set up logging", "error", err)
 	}
 
-	log.Println("Successfully retrieved EULA blocked servers")
-
 	if config.MongoDB != nil {
 		if err = db.Connect(); err != nil {
-			log.Fatalf("Failed to connect to MongoDB: %v", err)
+			Fatal("Failed to connect to MongoDB", "error", err)
 		}
 
-		log.Println("Successfully connected to MongoDB")
+		slog.Info("Successfully connected to MongoDB")
+
+		if err = db.CreateCollections(); err != nil {
+			Fatal("Failed to create MongoDB collections", "error", err)
+		}
 	}
 
 	if config.Redis != nil {
 		if err = r.Connect(); err != nil {
-			log.Fatalf("Failed to connect to Redis: %v", err)
+			Fatal("Failed to connect to Redis", "error", err)
 		}
 
-		log.Println("Successfully connected to Redis")
+		slog.Info("Successfully connected to Redis")
+	}
+
+	if err = LoadBlocklistDictionary(); err != nil {
+		Fatal("Failed to read blocklist dictionary", "error", err, "file", config.Blocklist.DictionaryFile)
 	}
 
+	LoadBlockedServerList()
+
 	if instanceID, err = GetInstanceID(); err != nil {
 		panic(err)
 	}
 
+	SetupQuotas()
+	RegisterRoutes()
+
 	app.Hooks().OnListen(func(ld fiber.ListenData) error {
-		log.Printf("Listening on %s:%d\n", config.Host, config.Port+instanceID)
+		slog.Info("Listening", "host", config.Host, "port", config.Port+instanceID)
 
 		return nil
 	})
 }
 
 func main() {
+	Setup()
+
 	defer r.Close()
 	defer db.Close()
 
+	stopTracing, err := StartTracing()
+
+	if err != nil {
+		Fatal("Failed to start tracing", "error", err)
+	}
+
+	defer stopTracing(context.Background())
+
+	StartBlocklistRefresh()
+
+	go func() {
+		if err := BackfillKnownBlocklistServers(); err != nil {
+			slog.Error("Failed to backfill known EULA blocked servers", "error", err)
+		}
+	}()
+
+	if config.Monitor.Enable && config.MongoDB != nil {
+		StartMonitor()
+	}
+
+	if config.Webhooks.Enable && config.MongoDB != nil {
+		StartWebhooks()
+	}
+
 	if err := app.Listen(fmt.Sprintf("%s:%d", config.Host, config.Port+instanceID)); err != nil {
 		panic(err)
 	}
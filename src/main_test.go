@@ -0,0 +1,14 @@
+package main
+
+import (
+	"os"
+	"testing"
+)
+
+// TestMain runs the tests against the default config, without reading the config file or connecting to any services,
+// so Redis and MongoDB methods behave as if neither is configured.
+func TestMain(m *testing.M) {
+	SetupQuotas()
+
+	os.Exit(m.Run())
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/event"
+)
+
+var (
+	metricsRegistry *prometheus.Registry   = prometheus.NewRegistry()
+	requestsTotal   *prometheus.CounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "The total number of HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+	requestDuration *prometheus.HistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "The duration of HTTP requests by route, method and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+	cacheLookupsTotal *prometheus.CounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_lookups_total",
+		Help: "The total number of cache lookups by entry type and result, which is either hit, stale or miss.",
+	}, []string{"type", "result"})
+	probeDuration *prometheus.HistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "probe_duration_seconds",
+		Help:    "The duration of probes by probe type and outcome.",
+		Buckets: []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"probe", "outcome"})
+	lockWaitDuration *prometheus.HistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "lock_wait_duration_seconds",
+		Help:    "The time spent acquiring a fetch lock or waiting on its holder, by lock type and outcome.",
+		Buckets: []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10},
+	}, []string{"lock", "outcome"})
+	redisCommandDuration *prometheus.HistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "redis_command_duration_seconds",
+		Help:    "The duration of Redis commands by command name.",
+		Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"command"})
+	redisCommandErrors *prometheus.CounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "redis_command_errors_total",
+		Help: "The total number of failed Redis commands by command name.",
+	}, []string{"command"})
+	mongoCommandDuration *prometheus.HistogramVec = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mongodb_command_duration_seconds",
+		Help:    "The duration of MongoDB commands by command name.",
+		Buckets: []float64{0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1},
+	}, []string{"command"})
+	mongoCommandErrors *prometheus.CounterVec = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mongodb_command_errors_total",
+		Help: "The total number of failed MongoDB commands by command name.",
+	}, []string{"command"})
+	blocklistSize prometheus.GaugeFunc = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blocklist_size",
+		Help: "The number of hashes in the blocked servers list.",
+	}, func() float64 {
+		list := blockedServers.Load()
+
+		if list == nil {
+			return 0
+		}
+
+		return float64(list.Hashes.Len())
+	})
+	blocklistAge prometheus.GaugeFunc = prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "blocklist_age_seconds",
+		Help: "The number of seconds since the blocked servers list was last retrieved.",
+	}, func() float64 {
+		list := blockedServers.Load()
+
+		if list == nil {
+			return 0
+		}
+
+		return time.Since(list.UpdatedAt).Seconds()
+	})
+)
+
+func init() {
+	metricsRegistry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsTotal,
+		requestDuration,
+		cacheLookupsTotal,
+		probeDuration,
+		lockWaitDuration,
+		redisCommandDuration,
+		redisCommandErrors,
+		mongoCommandDuration,
+		mongoCommandErrors,
+		blocklistSize,
+		blocklistAge,
+	)
+}
+
+// MetricsMiddleware records the count and duration of every request by the route that handled it. Errors are passed to
+// the error handler first so that the status code recorded is the one sent to the client.
+func MetricsMiddleware(ctx *fiber.Ctx) error {
+	start := time.Now()
+
+	if err := ctx.Next(); err != nil {
+		if err = ctx.App().ErrorHandler(ctx, err); err != nil {
+			_ = ctx.SendStatus(fiber.StatusInternalServerError)
+		}
+	}
+
+	// Label values are kept by the metric vectors, so the method is copied out of the request buffer that is reused once
+	// the request has been handled
+	labels := prometheus.Labels{
+		"route":  ctx.Route().Path,
+		"method": utils.CopyString(ctx.Method()),
+		"status": strconv.Itoa(ctx.Response().StatusCode()),
+	}
+
+	requestsTotal.With(labels).Inc()
+	requestDuration.With(labels).Observe(time.Since(start).Seconds())
+
+	return nil
+}
+
+// ObserveCacheLookup records the result of looking up an entry of the given type in the cache.
+func ObserveCacheLookup(entryType string, cacheStatus *CacheStatus) {
+	result := "miss"
+
+	if cacheStatus != nil && cacheStatus.Hit {
+		if cacheStatus.Stale {
+			result = "stale"
+		} else {
+			result = "hit"
+		}
+	}
+
+	cacheLookupsTotal.WithLabelValues(entryType, result).Inc()
+}
+
+// ObserveProbe records the duration and outcome of a single probe that started at the given time, and adds the duration
+// to the access log of the request the probe was made for.
+func ObserveProbe(ctx context.Context, probe string, start time.Time, err error) {
+	duration := time.Since(start)
+
+	probeDuration.WithLabelValues(probe, ProbeOutcome(err)).Observe(duration.Seconds())
+
+	if details := GetRequestDetails(ctx); details != nil {
+		details.AddProbeDuration(probe, duration)
+	}
+}
+
+// ProbeOutcome returns the outcome of a probe from the error it returned.
+func ProbeOutcome(err error) string {
+	if errors.Is(err, context.Canceled) {
+		return "cancelled"
+	} else if errors.Is(err, context.DeadlineExceeded) {
+		return "timeout"
+	} else if err != nil {
+		return "failure"
+	}
+
+	return "success"
+}
+
+// ObserveLockWait records the time spent on a lock that started at the given time, using the prefix of the lock key as
+// the lock type.
+func ObserveLockWait(lockKey, outcome string, start time.Time) {
+	lock, _, _ := strings.Cut(lockKey, ":")
+
+	lockWaitDuration.WithLabelValues(lock, outcome).Observe(time.Since(start).Seconds())
+}
+
+// redisMetricsHook is a Redis client hook that records the duration and errors of every command.
+type redisMetricsHook struct{}
+
+func (redisMetricsHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return next(ctx, network, addr)
+	}
+}
+
+func (redisMetricsHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		start := time.Now()
+
+		err := next(ctx, cmd)
+
+		redisCommandDuration.WithLabelValues(cmd.Name()).Observe(time.Since(start).Seconds())
+
+		if err != nil && !errors.Is(err, redis.Nil) {
+			redisCommandErrors.WithLabelValues(cmd.Name()).Inc()
+		}
+
+		return err
+	}
+}
+
+func (redisMetricsHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		start := time.Now()
+
+		err := next(ctx, cmds)
+
+		redisCommandDuration.WithLabelValues("pipeline").Observe(time.Since(start).Seconds())
+
+		if err != nil && !errors.Is(err, redis.Nil) {
+			redisCommandErrors.WithLabelValues("pipeline").Inc()
+		}
+
+		return err
+	}
+}
+
+// NewMongoCommandMonitor returns a MongoDB command monitor that records the duration and errors of every command.
+func NewMongoCommandMonitor() *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			mongoCommandDuration.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			mongoCommandDuration.WithLabelValues(e.CommandName).Observe(e.Duration.Seconds())
+			mongoCommandErrors.WithLabelValues(e.CommandName).Inc()
+		},
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,9 +14,16 @@ import (
 )
 
 var (
-	CollectionApplications string = "applications"
-	CollectionTokens       string = "tokens"
-	CollectionRequestLog   string = "request_log"
+	CollectionApplications      string = "applications"
+	CollectionTokens            string = "tokens"
+	CollectionRequestLog        string = "request_log"
+	CollectionMonitors          string = "monitors"
+	CollectionStatusHistory     string = "status_history"
+	CollectionWebhooks          string = "webhooks"
+	CollectionWebhookStates     string = "webhook_states"
+	CollectionWebhookDeliveries string = "webhook_deliveries"
+	CollectionBlocklistChanges  string = "blocklist_changes"
+	CollectionPlans             string = "plans"
 
 	ErrMongoNotConnected error = errors.New("cannot use method as MongoDB is not connected")
 )
@@ -23,16 +31,41 @@ var (
 type MongoDB struct {
 	Client   *mongo.Client
 	Database *mongo.Database
+	ctx      context.Context
 }
 
 type Application struct {
-	ID               string    `bson:"_id" json:"id"`
-	Name             string    `bson:"name" json:"name"`
-	ShortDescription string    `bson:"shortDescription" json:"shortDescription"`
-	User             string    `bson:"user" json:"user"`
-	Token            string    `bson:"token" json:"token"`
-	RequestCount     uint64    `bson:"requestCount" json:"requestCount"`
-	CreatedAt        time.Time `bson:"createdAt" json:"createdAt"`
+	ID               string                `bson:"_id" json:"id"`
+	Name             string                `bson:"name" json:"name"`
+	ShortDescription string                `bson:"shortDescription" json:"shortDescription"`
+	User             string                `bson:"user" json:"user"`
+	Token            string                `bson:"token" json:"token"`
+	RequestCount     uint64                `bson:"requestCount" json:"requestCount"`
+	Plan             string                `bson:"plan" json:"plan"`
+	RateLimit        *ApplicationRateLimit `bson:"rateLimit" json:"rateLimit"`
+	CreatedAt        time.Time             `bson:"createdAt" json:"createdAt"`
+}
+
+type Plan struct {
+	ID               string   `bson:"_id" json:"id"`
+	Name             string   `bson:"name" json:"name"`
+	DailyQuota       int64    `bson:"dailyQuota" json:"dailyQuota"`
+	MonthlyQuota     int64    `bson:"monthlyQuota" json:"monthlyQuota"`
+	SoftLimit        float64  `bson:"softLimit" json:"softLimit"`
+	FreshFetchWeight *float64 `bson:"freshFetchWeight" json:"freshFetchWeight"`
+	CacheHitWeight   *float64 `bson:"cacheHitWeight" json:"cacheHitWeight"`
+}
+
+type ApplicationUsage struct {
+	DailyRequests    int64 `bson:"dailyRequests"`
+	DailyCacheHits   int64 `bson:"dailyCacheHits"`
+	MonthlyRequests  int64 `bson:"monthlyRequests"`
+	MonthlyCacheHits int64 `bson:"monthlyCacheHits"`
+}
+
+type ApplicationRateLimit struct {
+	Limit  int64 `bson:"limit" json:"limit"`
+	Window int64 `bson:"window" json:"window"`
 }
 
 type Token struct {
@@ -45,8 +78,126 @@ type Token struct {
 	LastUsedAt   time.Time `bson:"lastUsedAt" json:"lastUsedAt"`
 }
 
+type Monitor struct {
+	ID           string     `bson:"_id" json:"id"`
+	Application  string     `bson:"application" json:"application"`
+	Edition      string     `bson:"edition" json:"edition"`
+	Host         string     `bson:"host" json:"host"`
+	Port         uint16     `bson:"port" json:"port"`
+	Interval     int64      `bson:"interval" json:"interval"`
+	Query        bool       `bson:"query" json:"query"`
+	Timeout      float64    `bson:"timeout" json:"timeout"`
+	CreatedAt    time.Time  `bson:"createdAt" json:"createdAt"`
+	NextPollAt   time.Time  `bson:"nextPollAt" json:"nextPollAt"`
+	LastPolledAt *time.Time `bson:"lastPolledAt" json:"lastPolledAt"`
+}
+
+type StatusSample struct {
+	Timestamp     time.Time        `bson:"timestamp" json:"timestamp"`
+	Meta          StatusSampleMeta `bson:"meta" json:"-"`
+	Online        bool             `bson:"online" json:"online"`
+	PlayersOnline *int64           `bson:"playersOnline" json:"playersOnline"`
+	PlayersMax    *int64           `bson:"playersMax" json:"playersMax"`
+	Latency       int64            `bson:"latency" json:"latency"`
+	Version       *string          `bson:"version" json:"version"`
+	MOTDHash      *string          `bson:"motdHash" json:"motdHash"`
+}
+
+type StatusSampleMeta struct {
+	Monitor string `bson:"monitor"`
+	Edition string `bson:"edition"`
+	Host    string `bson:"host"`
+	Port    uint16 `bson:"port"`
+}
+
+type StatusHistoryBucket struct {
+	Timestamp     time.Time `bson:"_id" json:"timestamp"`
+	Samples       int64     `bson:"samples" json:"samples"`
+	OnlineSamples int64     `bson:"onlineSamples" json:"onlineSamples"`
+	PlayersMin    *int64    `bson:"playersMin" json:"playersMin"`
+	PlayersAvg    *float64  `bson:"playersAvg" json:"playersAvg"`
+	PlayersMax    *int64    `bson:"playersMax" json:"playersMax"`
+}
+
+type UptimeCount struct {
+	Samples       int64 `bson:"samples"`
+	OnlineSamples int64 `bson:"onlineSamples"`
+}
+
+type Webhook struct {
+	ID          string           `bson:"_id" json:"id"`
+	Application string           `bson:"application" json:"application"`
+	URL         string           `bson:"url" json:"url"`
+	Format      string           `bson:"format" json:"format"`
+	Secret      string           `bson:"secret" json:"-"`
+	Addresses   []WebhookAddress `bson:"addresses" json:"addresses"`
+	Rules       []WebhookRule    `bson:"rules" json:"rules"`
+	Interval    int64            `bson:"interval" json:"interval"`
+	Query       bool             `bson:"query" json:"query"`
+	Timeout     float64          `bson:"timeout" json:"timeout"`
+	CreatedAt   time.Time        `bson:"createdAt" json:"createdAt"`
+	NextPollAt  time.Time        `bson:"nextPollAt" json:"nextPollAt"`
+}
+
+type WebhookAddress struct {
+	Edition string `bson:"edition" json:"edition"`
+	Host    string `bson:"host" json:"host"`
+	Port    uint16 `bson:"port" json:"port"`
+}
+
+type WebhookRule struct {
+	Type      string `bson:"type" json:"type"`
+	Threshold *int64 `bson:"threshold" json:"threshold"`
+}
+
+type WebhookState struct {
+	ID        string       `bson:"_id"`
+	Webhook   string       `bson:"webhook"`
+	Sample    StatusSample `bson:"sample"`
+	UpdatedAt time.Time    `bson:"updatedAt"`
+}
+
+type WebhookDelivery struct {
+	ID          string         `bson:"_id" json:"id"`
+	Webhook     string         `bson:"webhook" json:"webhook"`
+	Application string         `bson:"application" json:"application"`
+	Event       string         `bson:"event" json:"event"`
+	Address     WebhookAddress `bson:"address" json:"address"`
+	Payload     string         `bson:"payload" json:"payload"`
+	Attempts    int            `bson:"attempts" json:"attempts"`
+	StatusCode  *int           `bson:"statusCode" json:"statusCode"`
+	Success     bool           `bson:"success" json:"success"`
+	Error       *string        `bson:"error" json:"error"`
+	CreatedAt   time.Time      `bson:"createdAt" json:"createdAt"`
+	CompletedAt time.Time      `bson:"completedAt" json:"completedAt"`
+}
+
+type BlocklistChange struct {
+	ID        string    `bson:"_id" json:"id"`
+	Type      string    `bson:"type" json:"type"`
+	Hash      string    `bson:"hash" json:"hash"`
+	Pattern   *string   `bson:"pattern" json:"pattern"`
+	Servers   []string  `bson:"servers" json:"servers"`
+	Timestamp time.Time `bson:"timestamp" json:"timestamp"`
+}
+
+func (c *MongoDB) WithContext(ctx context.Context) *MongoDB {
+	result := *c
+	result.ctx = ctx
+
+	return &result
+}
+
+func (c *MongoDB) context() context.Context {
+	if c.ctx == nil {
+		return context.Background()
+	}
+
+	return c.ctx
+}
+
 func (c *MongoDB) Connect() error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -56,7 +207,7 @@ func (c *MongoDB) Connect() error {
 		return err
 	}
 
-	client, err := mongo.Connect(ctx, options.Client().ApplyURI(*config.MongoDB))
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(*config.MongoDB).SetMonitor(CombineCommandMonitors(NewMongoCommandMonitor(), NewMongoTracingMonitor())))
 
 	if err != nil {
 		return err
@@ -73,7 +224,7 @@ func (c *MongoDB) GetTokenByToken(token string) (*Token, error) {
 		return nil, ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -101,7 +252,7 @@ func (c *MongoDB) GetApplicationByID(id string) (*Application, error) {
 		return nil, ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -129,7 +280,7 @@ func (c *MongoDB) UpdateToken(id string, update bson.M) error {
 		return ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -147,7 +298,7 @@ func (c *MongoDB) IncrementApplicationRequestCount(id string) error {
 		return ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -169,7 +320,7 @@ func (c *MongoDB) UpsertRequestLog(query, update bson.M) error {
 		return ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
@@ -185,14 +336,887 @@ func (c *MongoDB) UpsertRequestLog(query, update bson.M) error {
 	return err
 }
 
-func (c *MongoDB) Close() error {
+func (c *MongoDB) CreateCollections() error {
 	if c.Client == nil {
-		return nil
+		return ErrMongoNotConnected
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
 
 	defer cancel()
 
-	return c.Client.Disconnect(ctx)
+	createOpts := options.CreateCollection().SetTimeSeriesOptions(
+		options.TimeSeries().
+			SetTimeField("timestamp").
+			SetMetaField("meta").
+			SetGranularity("minutes"),
+	)
+
+	if config.Monitor.HistoryRetention > 0 {
+		createOpts.SetExpireAfterSeconds(int64(config.Monitor.HistoryRetention.Seconds()))
+	}
+
+	if err := c.Database.CreateCollection(ctx, CollectionStatusHistory, createOpts); err != nil {
+		var commandError mongo.CommandError
+
+		// Ignore the error if the collection already exists
+		if !errors.As(err, &commandError) || !commandError.HasErrorCode(48) {
+			return err
+		}
+	}
+
+	for _, collection := range []string{CollectionMonitors, CollectionWebhooks} {
+		if _, err := c.Database.Collection(collection).Indexes().CreateMany(ctx, []mongo.IndexModel{
+			{Keys: bson.D{{Key: "application", Value: 1}}},
+			{Keys: bson.D{{Key: "nextPollAt", Value: 1}}},
+		}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := c.Database.Collection(CollectionWebhookStates).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "webhook", Value: 1}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := c.Database.Collection(CollectionWebhookDeliveries).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "webhook", Value: 1}, {Key: "createdAt", Value: -1}},
+	}); err != nil {
+		return err
+	}
+
+	if _, err := c.Database.Collection(CollectionBlocklistChanges).Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+		{Keys: bson.D{{Key: "hash", Value: 1}}},
+	}); err != nil {
+		return err
+	}
+
+	_, err := c.Database.Collection(CollectionRequestLog).Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "application", Value: 1}, {Key: "timestamp", Value: 1}},
+	})
+
+	return err
+}
+
+func (c *MongoDB) GetMonitorsByApplication(application string) ([]Monitor, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionMonitors).Find(ctx, bson.M{"application": application})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Monitor, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetMonitorByAddress(application, edition, host string, port uint16) (*Monitor, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionMonitors).FindOne(
+		ctx,
+		bson.M{"application": application, "edition": edition, "host": host, "port": port},
+		options.FindOne().SetSort(bson.M{"createdAt": 1}),
+	)
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result Monitor
+
+	if err := cur.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *MongoDB) GetDueMonitors(now time.Time, limit int64) ([]Monitor, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionMonitors).Find(
+		ctx,
+		bson.M{"nextPollAt": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.M{"nextPollAt": 1}).SetLimit(limit),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Monitor, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) CountMonitorsByApplication(application string) (int64, error) {
+	if c.Client == nil {
+		return 0, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	return c.Database.Collection(CollectionMonitors).CountDocuments(ctx, bson.M{"application": application})
+}
+
+func (c *MongoDB) InsertMonitor(monitor Monitor) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionMonitors).InsertOne(ctx, monitor)
+
+	return err
+}
+
+func (c *MongoDB) UpdateMonitor(id string, update bson.M) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionMonitors).UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		update,
+	)
+
+	return err
+}
+
+func (c *MongoDB) ClaimMonitor(id string, now time.Time, update bson.M) (bool, error) {
+	if c.Client == nil {
+		return false, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionMonitors).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "nextPollAt": bson.M{"$lte": now}},
+		update,
+	)
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *MongoDB) DeleteMonitor(id, application string) (bool, error) {
+	if c.Client == nil {
+		return false, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	result, err := c.Database.Collection(CollectionMonitors).DeleteOne(ctx, bson.M{"_id": id, "application": application})
+
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+func (c *MongoDB) InsertStatusSample(sample StatusSample) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionStatusHistory).InsertOne(ctx, sample)
+
+	return err
+}
+
+func (c *MongoDB) GetStatusSamples(monitor string, from, to time.Time, limit int64) ([]StatusSample, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionStatusHistory).Find(
+		ctx,
+		bson.M{
+			"meta.monitor": monitor,
+			"timestamp":    bson.M{"$gte": from, "$lt": to},
+		},
+		options.Find().SetSort(bson.M{"timestamp": 1}).SetLimit(limit),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]StatusSample, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetStatusHistoryBuckets(monitor string, from, to time.Time, unit string, binSize int) ([]StatusHistoryBucket, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionStatusHistory).Aggregate(ctx, bson.A{
+		bson.M{
+			"$match": bson.M{
+				"meta.monitor": monitor,
+				"timestamp":    bson.M{"$gte": from, "$lt": to},
+			},
+		},
+		bson.M{
+			"$group": bson.M{
+				"_id": bson.M{
+					"$dateTrunc": bson.M{
+						"date":    "$timestamp",
+						"unit":    unit,
+						"binSize": binSize,
+					},
+				},
+				"samples":       bson.M{"$sum": 1},
+				"onlineSamples": bson.M{"$sum": bson.M{"$cond": bson.A{"$online", 1, 0}}},
+				"playersMin":    bson.M{"$min": "$playersOnline"},
+				"playersAvg":    bson.M{"$avg": "$playersOnline"},
+				"playersMax":    bson.M{"$max": "$playersOnline"},
+			},
+		},
+		bson.M{
+			"$sort": bson.M{"_id": 1},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]StatusHistoryBucket, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetClosestStatusSample(monitor string, at time.Time) (*StatusSample, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	var result *StatusSample = nil
+
+	for _, direction := range []int{-1, 1} {
+		operator := "$lte"
+
+		if direction > 0 {
+			operator = "$gte"
+		}
+
+		cur := c.Database.Collection(CollectionStatusHistory).FindOne(
+			ctx,
+			bson.M{
+				"meta.monitor": monitor,
+				"timestamp":    bson.M{operator: at},
+			},
+			options.FindOne().SetSort(bson.M{"timestamp": direction}),
+		)
+
+		if err := cur.Err(); err != nil {
+			if errors.Is(err, mongo.ErrNoDocuments) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		var sample StatusSample
+
+		if err := cur.Decode(&sample); err != nil {
+			return nil, err
+		}
+
+		if result == nil || sample.Timestamp.Sub(at).Abs() < result.Timestamp.Sub(at).Abs() {
+			result = &sample
+		}
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetUptimeCounts(monitor string, since []time.Time) ([]UptimeCount, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	if len(since) < 1 {
+		return []UptimeCount{}, nil
+	}
+
+	earliest := since[0]
+	facets := bson.M{}
+
+	for i, t := range since {
+		if t.Before(earliest) {
+			earliest = t
+		}
+
+		facets[strconv.Itoa(i)] = bson.A{
+			bson.M{"$match": bson.M{"timestamp": bson.M{"$gte": t}}},
+			bson.M{
+				"$group": bson.M{
+					"_id":           nil,
+					"samples":       bson.M{"$sum": 1},
+					"onlineSamples": bson.M{"$sum": bson.M{"$cond": bson.A{"$online", 1, 0}}},
+				},
+			},
+		}
+	}
+
+	cur, err := c.Database.Collection(CollectionStatusHistory).Aggregate(ctx, bson.A{
+		bson.M{
+			"$match": bson.M{
+				"meta.monitor": monitor,
+				"timestamp":    bson.M{"$gte": earliest},
+			},
+		},
+		bson.M{"$facet": facets},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	var facetResults []map[string][]UptimeCount
+
+	if err = cur.All(ctx, &facetResults); err != nil {
+		return nil, err
+	}
+
+	result := make([]UptimeCount, len(since))
+
+	if len(facetResults) < 1 {
+		return result, nil
+	}
+
+	for i := range since {
+		if counts := facetResults[0][strconv.Itoa(i)]; len(counts) > 0 {
+			result[i] = counts[0]
+		}
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetWebhooksByApplication(application string) ([]Webhook, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionWebhooks).Find(ctx, bson.M{"application": application})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Webhook, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetWebhookByID(id, application string) (*Webhook, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionWebhooks).FindOne(ctx, bson.M{"_id": id, "application": application})
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result Webhook
+
+	if err := cur.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *MongoDB) GetDueWebhooks(now time.Time, limit int64) ([]Webhook, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionWebhooks).Find(
+		ctx,
+		bson.M{"nextPollAt": bson.M{"$lte": now}},
+		options.Find().SetSort(bson.M{"nextPollAt": 1}).SetLimit(limit),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Webhook, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) CountWebhooksByApplication(application string) (int64, error) {
+	if c.Client == nil {
+		return 0, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	return c.Database.Collection(CollectionWebhooks).CountDocuments(ctx, bson.M{"application": application})
+}
+
+func (c *MongoDB) InsertWebhook(webhook Webhook) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionWebhooks).InsertOne(ctx, webhook)
+
+	return err
+}
+
+func (c *MongoDB) UpdateWebhook(id string, update bson.M) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionWebhooks).UpdateOne(
+		ctx,
+		bson.M{"_id": id},
+		update,
+	)
+
+	return err
+}
+
+func (c *MongoDB) ClaimWebhook(id string, now time.Time, update bson.M) (bool, error) {
+	if c.Client == nil {
+		return false, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionWebhooks).FindOneAndUpdate(
+		ctx,
+		bson.M{"_id": id, "nextPollAt": bson.M{"$lte": now}},
+		update,
+	)
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *MongoDB) DeleteWebhook(id, application string) (bool, error) {
+	if c.Client == nil {
+		return false, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	result, err := c.Database.Collection(CollectionWebhooks).DeleteOne(ctx, bson.M{"_id": id, "application": application})
+
+	if err != nil {
+		return false, err
+	}
+
+	if result.DeletedCount < 1 {
+		return false, nil
+	}
+
+	if _, err = c.Database.Collection(CollectionWebhookStates).DeleteMany(ctx, bson.M{"webhook": id}); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (c *MongoDB) GetWebhookState(id string) (*WebhookState, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionWebhookStates).FindOne(ctx, bson.M{"_id": id})
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result WebhookState
+
+	if err := cur.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *MongoDB) UpsertWebhookState(state WebhookState) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionWebhookStates).ReplaceOne(
+		ctx,
+		bson.M{"_id": state.ID},
+		state,
+		options.Replace().SetUpsert(true),
+	)
+
+	return err
+}
+
+func (c *MongoDB) InsertWebhookDelivery(delivery WebhookDelivery) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionWebhookDeliveries).InsertOne(ctx, delivery)
+
+	return err
+}
+
+func (c *MongoDB) GetWebhookDeliveries(webhook string, limit int64) ([]WebhookDelivery, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionWebhookDeliveries).Find(
+		ctx,
+		bson.M{"webhook": webhook},
+		options.Find().SetSort(bson.M{"createdAt": -1}).SetLimit(limit),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WebhookDelivery, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) Ping() error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	return c.Client.Ping(ctx, nil)
+}
+
+func (c *MongoDB) Close() error {
+	if c.Client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	return c.Client.Disconnect(ctx)
+}
+
+func (c *MongoDB) GetWebhooksByRuleTypes(types []string) ([]Webhook, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionWebhooks).Find(ctx, bson.M{"rules.type": bson.M{"$in": types}})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Webhook, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) InsertBlocklistChanges(changes []BlocklistChange) error {
+	if c.Client == nil {
+		return ErrMongoNotConnected
+	}
+
+	if len(changes) < 1 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	_, err := c.Database.Collection(CollectionBlocklistChanges).InsertMany(ctx, Map(changes, func(change BlocklistChange) interface{} { return change }))
+
+	return err
+}
+
+func (c *MongoDB) GetBlocklistChanges(since time.Time, limit int64) ([]BlocklistChange, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur, err := c.Database.Collection(CollectionBlocklistChanges).Find(
+		ctx,
+		bson.M{"timestamp": bson.M{"$gte": since}},
+		options.Find().SetSort(bson.D{{Key: "timestamp", Value: -1}, {Key: "_id", Value: 1}}).SetLimit(limit),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]BlocklistChange, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (c *MongoDB) GetPlanByID(id string) (*Plan, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	cur := c.Database.Collection(CollectionPlans).FindOne(ctx, bson.M{"_id": id})
+
+	if err := cur.Err(); err != nil {
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var result Plan
+
+	if err := cur.Decode(&result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *MongoDB) GetApplicationUsage(application string, dayStart, monthStart time.Time) (*ApplicationUsage, error) {
+	if c.Client == nil {
+		return nil, ErrMongoNotConnected
+	}
+
+	ctx, cancel := context.WithTimeout(c.context(), time.Second*5)
+
+	defer cancel()
+
+	inDay := func(value interface{}) bson.M {
+		return bson.M{"$cond": bson.A{bson.M{"$gte": bson.A{"$timestamp", dayStart}}, value, 0}}
+	}
+
+	cacheHits := bson.M{"$ifNull": bson.A{"$cacheHitCount", 0}}
+
+	cur, err := c.Database.Collection(CollectionRequestLog).Aggregate(ctx, bson.A{
+		bson.M{
+			"$match": bson.M{
+				"application": application,
+				"timestamp":   bson.M{"$gte": monthStart},
+			},
+		},
+		bson.M{
+			"$group": bson.M{
+				"_id":              nil,
+				"dailyRequests":    bson.M{"$sum": inDay("$requestCount")},
+				"dailyCacheHits":   bson.M{"$sum": inDay(cacheHits)},
+				"monthlyRequests":  bson.M{"$sum": "$requestCount"},
+				"monthlyCacheHits": bson.M{"$sum": cacheHits},
+			},
+		},
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]ApplicationUsage, 0)
+
+	if err = cur.All(ctx, &result); err != nil {
+		return nil, err
+	}
+
+	if len(result) < 1 {
+		return &ApplicationUsage{}, nil
+	}
+
+	return &result[0], nil
 }
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StartMonitor starts polling the registered monitors in the background. Every instance scans for monitors that are due,
+// and a lock is held on each monitor while it is being polled so that the work is split between instances.
+func StartMonitor() {
+	StartScheduler(
+		"monitor",
+		config.Monitor.ScanInterval,
+		config.Monitor.Concurrency,
+		func(limit int64) ([]Monitor, error) {
+			return db.GetDueMonitors(time.Now().UTC(), limit)
+		},
+		func(monitor Monitor) string {
+			return monitor.ID
+		},
+		PollMonitor,
+	)
+}
+
+// StartScheduler periodically retrieves the items that are due and polls each of them in the background, with at most
+// the concurrency limit of polls running at once. Items that cannot be polled because every worker is busy are left for
+// the next scan.
+func StartScheduler[T any](name string, scanInterval time.Duration, concurrency int, getDue func(limit int64) ([]T, error), getID func(T) string, poll func(T) error) {
+	semaphore := make(chan struct{}, max(concurrency, 1))
+
+	go func() {
+		ticker := time.NewTicker(scanInterval)
+
+		defer ticker.Stop()
+
+		for range ticker.C {
+			items, err := getDue(int64(cap(semaphore)))
+
+			if err != nil {
+				slog.Error("Failed to retrieve due items", "error", err, "scheduler", name)
+
+				continue
+			}
+
+			for _, item := range items {
+				select {
+				case semaphore <- struct{}{}:
+					go func(item T) {
+						defer func() { <-semaphore }()
+
+						if err := poll(item); err != nil {
+							slog.Error("Failed to poll item", "error", err, "scheduler", name, "id", getID(item))
+						}
+					}(item)
+				default:
+					// Every worker is busy, the remaining items will be polled on the next scan
+				}
+			}
+		}
+	}()
+}
+
+// PollMonitor fetches a fresh status of the monitored server and stores it as a sample in the status history, unless
+// another instance is already polling the same monitor or has polled it since it was found to be due.
+func PollMonitor(monitor Monitor) error {
+	mutex := r.NewMutex(fmt.Sprintf("monitor-lock:%s", monitor.ID))
+
+	acquired, err := mutex.TryLock()
+
+	if err != nil || !acquired {
+		return err
+	}
+
+	defer mutex.KeepAlive()()
+
+	now := time.Now().UTC()
+
+	// Another instance may have polled the monitor between the scan and acquiring the lock, so the next poll is only
+	// scheduled if the monitor is still due
+	claimed, err := db.ClaimMonitor(monitor.ID, now, bson.M{
+		"$set": bson.M{
+			"nextPollAt":   GetNextPollTime(now, time.Duration(monitor.Interval)*time.Second),
+			"lastPolledAt": now,
+		},
+	})
+
+	if err != nil || !claimed {
+		return err
+	}
+
+	sample, err := FetchStatusSample(monitor.Edition, monitor.Host, monitor.Port, &StatusOptions{
+		Query:   monitor.Query,
+		Timeout: time.Duration(monitor.Timeout * float64(time.Second)),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	sample.Meta.Monitor = monitor.ID
+
+	return db.InsertStatusSample(*sample)
+}
+
+// FetchStatusSample fetches a fresh status of the server and converts it into a status history sample. The latency of
+// the sample is the round trip time of a ping to a Java Edition server, or of the status request to a Bedrock Edition
+// server, and does not include resolving the address or any probes other than the status itself.
+func FetchStatusSample(edition, host string, port uint16, opts *StatusOptions) (*StatusSample, error) {
+	trace := &ProbeTrace{}
+
+	// Tracing the probe enables the ping of Java Edition servers
+	sampleOpts := *opts
+	sampleOpts.Trace = trace
+	opts = &sampleOpts
+
+	sample := &StatusSample{
+		Timestamp: time.Now().UTC(),
+		Meta: StatusSampleMeta{
+			Edition: edition,
+			Host:    host,
+			Port:    port,
+		},
+	}
+
+	switch edition {
+	case "java":
+		{
+			response, err := FetchJavaStatus(host, port, opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			sample.Online = response.Online
+
+			if response.JavaStatus != nil {
+				sample.PlayersOnline = response.Players.Online
+				sample.PlayersMax = response.Players.Max
+				sample.MOTDHash = PointerOf(SHA256(response.MOTD.Raw))
+
+				if response.Version != nil {
+					sample.Version = PointerOf(response.Version.NameClean)
+				}
+			}
+		}
+	case "bedrock":
+		{
+			response, err := FetchBedrockStatus(host, port, opts)
+
+			if err != nil {
+				return nil, err
+			}
+
+			sample.Online = response.Online
+
+			if response.BedrockStatus != nil {
+				if response.Players != nil {
+					sample.PlayersOnline = response.Players.Online
+					sample.PlayersMax = response.Players.Max
+				}
+
+				if response.MOTD != nil {
+					sample.MOTDHash = PointerOf(SHA256(response.MOTD.Raw))
+				}
+
+				if response.Version != nil {
+					sample.Version = response.Version.Name
+				}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unknown edition: %s", edition)
+	}
+
+	switch edition {
+	case "java":
+		sample.Latency = trace.Ping.Milliseconds()
+	case "bedrock":
+		sample.Latency = trace.Status.Milliseconds()
+	}
+
+	return sample, nil
+}
+
+// GetNextPollTime returns the time of the next poll after the interval, with a random jitter added so that polls of
+// monitors registered at the same time do not pile up.
+func GetNextPollTime(now time.Time, interval time.Duration) time.Time {
+	jitter := min(config.Monitor.MaxJitter, interval/2)
+
+	if jitter <= 0 {
+		return now.Add(interval)
+	}
+
+	return now.Add(interval + time.Duration(rand.Int63n(int64(jitter))))
+}
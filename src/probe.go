@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/mcstatus-io/mcutil/v4/options"
+	"github.com/mcstatus-io/mcutil/v4/query"
+	"github.com/mcstatus-io/mcutil/v4/status"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	// JavaProbeKindStatus is a probe that retrieves the status of the server, which all other probes wait on.
+	JavaProbeKindStatus JavaProbeKind = iota
+	// JavaProbeKindSupplementary is a probe that retrieves additional data, which is given a grace period to finish
+	// once all status probes have finished.
+	JavaProbeKindSupplementary
+)
+
+var (
+	javaProbes []*JavaProbe = []*JavaProbe{
+		{
+			Name:      "modern",
+			Kind:      JavaProbeKindStatus,
+			Preferred: true,
+			Enabled:   nil,
+			Run: func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error) {
+				start := time.Now()
+
+				// The ping round trip is only measured when the phases of the probe are traced
+				result, err := status.Modern(ctx, hostname, port, options.StatusModern{
+					EnableSRV:       !opts.DisableSRV,
+					Timeout:         opts.Timeout - time.Millisecond*100,
+					ProtocolVersion: 47,
+					Ping:            opts.Trace != nil,
+				})
+
+				if err != nil {
+					return nil, err
+				}
+
+				duration := time.Since(start)
+
+				return func(results *JavaProbeResults) {
+					results.Status = result
+
+					if opts.Trace != nil {
+						opts.Trace.Status = duration - result.Latency
+						opts.Trace.Ping = result.Latency
+					}
+				}, nil
+			},
+		},
+		{
+			Name:      "legacy",
+			Kind:      JavaProbeKindStatus,
+			Preferred: false,
+			Enabled:   nil,
+			Run: func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error) {
+				start := time.Now()
+
+				result, err := status.Legacy(ctx, hostname, port, options.StatusLegacy{
+					EnableSRV:       !opts.DisableSRV,
+					Timeout:         opts.Timeout - time.Millisecond*100,
+					ProtocolVersion: -1,
+				})
+
+				if err != nil {
+					return nil, err
+				}
+
+				duration := time.Since(start)
+
+				return func(results *JavaProbeResults) {
+					results.LegacyStatus = result
+
+					// The timing of the modern status takes precedence, as it is used for the response when both succeed
+					if opts.Trace != nil && results.Status == nil {
+						opts.Trace.Status = duration
+					}
+				}, nil
+			},
+		},
+		{
+			Name:      "connect",
+			Kind:      JavaProbeKindSupplementary,
+			Preferred: false,
+			Enabled: func(opts *StatusOptions) bool {
+				return opts.Trace != nil && opts.Trace.TimeConnect && len(opts.Trace.Address) > 0
+			},
+			Run: func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error) {
+				// The status library does not expose its connection, so the connect is timed on a connection of its own
+				start := time.Now()
+
+				conn, err := (&net.Dialer{}).DialContext(ctx, "tcp", opts.Trace.Address)
+
+				if err != nil {
+					return nil, err
+				}
+
+				duration := time.Since(start)
+
+				conn.Close()
+
+				return func(results *JavaProbeResults) { opts.Trace.Connect = duration }, nil
+			},
+		},
+		{
+			Name:      "query",
+			Kind:      JavaProbeKindSupplementary,
+			Preferred: false,
+			Enabled:   func(opts *StatusOptions) bool { return opts.Query },
+			Run: func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error) {
+				result, err := query.Full(ctx, hostname, port, options.Query{
+					Timeout: opts.Timeout - time.Millisecond*100,
+				})
+
+				if err != nil {
+					return nil, err
+				}
+
+				return func(results *JavaProbeResults) { results.Query = result }, nil
+			},
+		},
+	}
+)
+
+// JavaProbeKind is the kind of a Java Edition probe, which decides how long the probe is waited on.
+type JavaProbeKind int
+
+// JavaProbe is a single method of retrieving information about a Java Edition server, which is run concurrently with
+// all other probes. The result of the run function is a function that stores the result, which is only called by the
+// coordinator so results are never written concurrently.
+type JavaProbe struct {
+	Name      string
+	Kind      JavaProbeKind
+	Preferred bool
+	Enabled   func(opts *StatusOptions) bool
+	Run       func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error)
+}
+
+// javaProbeResult is the result of a single probe sent back to the coordinator.
+type javaProbeResult struct {
+	Probe *JavaProbe
+	Store func(*JavaProbeResults)
+	Error error
+}
+
+// RegisterJavaProbe adds the probe to the list of probes run when fetching the status of a Java Edition server.
+func RegisterJavaProbe(probe *JavaProbe) {
+	javaProbes = append(javaProbes, probe)
+}
+
+// RunJavaProbes runs all of the enabled probes concurrently and stores their results. It returns early once the
+// preferred status probe succeeds, which cancels the other status probes, and gives supplementary probes the query
+// grace period to finish once there are no status probes remaining.
+func RunJavaProbes(hostname string, port uint16, opts *StatusOptions, results *JavaProbeResults) {
+	ctx, cancel := context.WithTimeout(StatusContext(opts), opts.Timeout)
+
+	defer cancel()
+
+	var (
+		resultChan      chan javaProbeResult              = make(chan javaProbeResult, len(javaProbes))
+		running         map[*JavaProbe]context.CancelFunc = make(map[*JavaProbe]context.CancelFunc)
+		runningStatuses int                               = 0
+		graceTimer      <-chan time.Time                  = nil
+	)
+
+	stopProbe := func(probe *JavaProbe) {
+		running[probe]()
+
+		delete(running, probe)
+
+		if probe.Kind == JavaProbeKindStatus {
+			runningStatuses--
+		}
+	}
+
+	for _, probe := range javaProbes {
+		if probe.Enabled != nil && !probe.Enabled(opts) {
+			continue
+		}
+
+		probeCtx, probeCancel := context.WithCancel(ctx)
+
+		running[probe] = probeCancel
+
+		if probe.Kind == JavaProbeKindStatus {
+			runningStatuses++
+		}
+
+		go func(probe *JavaProbe) {
+			spanCtx, span := StartSpan(probeCtx, fmt.Sprintf("probe %s", probe.Name), attribute.String("minecraft.host", hostname), attribute.Int("minecraft.port", int(port)))
+			start := time.Now()
+
+			store, err := probe.Run(spanCtx, hostname, port, opts)
+
+			// Probes stopped by the coordinator may not return the context error themselves
+			if err != nil && probeCtx.Err() != nil {
+				err = probeCtx.Err()
+			}
+
+			ObserveProbe(spanCtx, probe.Name, start, err)
+
+			span.SetAttributes(attribute.String("probe.outcome", ProbeOutcome(err)))
+
+			EndSpan(span, err)
+
+			resultChan <- javaProbeResult{
+				Probe: probe,
+				Store: store,
+				Error: err,
+			}
+		}(probe)
+	}
+
+	for len(running) > 0 {
+		select {
+		case result := <-resultChan:
+			{
+				if _, ok := running[result.Probe]; !ok {
+					continue
+				}
+
+				stopProbe(result.Probe)
+
+				if result.Error == nil && result.Store != nil {
+					result.Store(results)
+
+					if result.Probe.Preferred {
+						for probe := range running {
+							if probe.Kind == JavaProbeKindStatus {
+								stopProbe(probe)
+							}
+						}
+					}
+				}
+
+				if runningStatuses < 1 && graceTimer == nil && len(running) > 0 {
+					graceTimer = time.After(config.Probe.QueryGracePeriod)
+				}
+			}
+		case <-graceTimer:
+			{
+				for probe := range running {
+					stopProbe(probe)
+				}
+			}
+		}
+	}
+}
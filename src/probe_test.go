@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mcstatus-io/mcutil/v4/response"
+)
+
+// useJavaProbes replaces the registered probes and the query grace period for the duration of the test.
+func useJavaProbes(t *testing.T, gracePeriod time.Duration, probes ...*JavaProbe) {
+	t.Helper()
+
+	previousProbes, previousGracePeriod := javaProbes, config.Probe.QueryGracePeriod
+
+	javaProbes, config.Probe.QueryGracePeriod = probes, gracePeriod
+
+	t.Cleanup(func() {
+		javaProbes, config.Probe.QueryGracePeriod = previousProbes, previousGracePeriod
+	})
+}
+
+// newFakeJavaProbe returns a probe that waits for the delay before calling the result function, or returns the error
+// of the context if it is stopped first. The cancelled counter is incremented when the probe is stopped.
+func newFakeJavaProbe(name string, kind JavaProbeKind, preferred bool, delay time.Duration, cancelled *atomic.Int32, result func() (func(*JavaProbeResults), error)) *JavaProbe {
+	return &JavaProbe{
+		Name:      name,
+		Kind:      kind,
+		Preferred: preferred,
+		Enabled:   nil,
+		Run: func(ctx context.Context, hostname string, port uint16, opts *StatusOptions) (func(*JavaProbeResults), error) {
+			select {
+			case <-time.After(delay):
+				return result()
+			case <-ctx.Done():
+				if cancelled != nil {
+					cancelled.Add(1)
+				}
+
+				return nil, ctx.Err()
+			}
+		},
+	}
+}
+
+func storeStatus() (func(*JavaProbeResults), error) {
+	return func(results *JavaProbeResults) { results.Status = &response.StatusModern{} }, nil
+}
+
+func storeQuery() (func(*JavaProbeResults), error) {
+	return func(results *JavaProbeResults) { results.Query = &response.QueryFull{} }, nil
+}
+
+func TestRunJavaProbesQueryAfterStatus(t *testing.T) {
+	var legacyCancelled atomic.Int32
+
+	useJavaProbes(t, time.Second,
+		newFakeJavaProbe("modern", JavaProbeKindStatus, true, 10*time.Millisecond, nil, storeStatus),
+		newFakeJavaProbe("legacy", JavaProbeKindStatus, false, time.Hour, &legacyCancelled, nil),
+		newFakeJavaProbe("query", JavaProbeKindSupplementary, false, 50*time.Millisecond, nil, storeQuery),
+	)
+
+	results := &JavaProbeResults{}
+
+	RunJavaProbes("localhost", 25565, &StatusOptions{Query: true, Timeout: 5 * time.Second}, results)
+
+	if results.Status == nil {
+		t.Error("expected the status probe result to be stored")
+	}
+
+	if results.Query == nil {
+		t.Error("expected the query probe result finishing within the grace period to be stored")
+	}
+
+	if legacyCancelled.Load() != 1 {
+		t.Error("expected the legacy probe to be stopped once the preferred probe succeeded")
+	}
+}
+
+func TestRunJavaProbesQueryTimesOutInGracePeriod(t *testing.T) {
+	var queryCancelled atomic.Int32
+
+	useJavaProbes(t, 50*time.Millisecond,
+		newFakeJavaProbe("modern", JavaProbeKindStatus, true, 10*time.Millisecond, nil, storeStatus),
+		newFakeJavaProbe("query", JavaProbeKindSupplementary, false, time.Hour, &queryCancelled, storeQuery),
+	)
+
+	results := &JavaProbeResults{}
+	start := time.Now()
+
+	RunJavaProbes("localhost", 25565, &StatusOptions{Query: true, Timeout: 5 * time.Second}, results)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected probes to stop after the grace period, took %s", elapsed)
+	}
+
+	if results.Status == nil {
+		t.Error("expected the status probe result to be stored")
+	}
+
+	if results.Query != nil {
+		t.Error("expected no query result after the grace period ended")
+	}
+
+	// The coordinator does not wait on stopped probes, so give the query probe time to observe the cancellation
+	deadline := time.Now().Add(time.Second)
+
+	for queryCancelled.Load() < 1 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	if queryCancelled.Load() != 1 {
+		t.Error("expected the query probe to be stopped at the end of the grace period")
+	}
+}
+
+func TestRunJavaProbesAllFail(t *testing.T) {
+	fail := func() (func(*JavaProbeResults), error) { return nil, errors.New("connection refused") }
+
+	useJavaProbes(t, time.Second,
+		newFakeJavaProbe("modern", JavaProbeKindStatus, true, 10*time.Millisecond, nil, fail),
+		newFakeJavaProbe("legacy", JavaProbeKindStatus, false, 20*time.Millisecond, nil, fail),
+		newFakeJavaProbe("query", JavaProbeKindSupplementary, false, 30*time.Millisecond, nil, fail),
+	)
+
+	results := &JavaProbeResults{}
+	start := time.Now()
+
+	RunJavaProbes("localhost", 25565, &StatusOptions{Query: true, Timeout: 5 * time.Second}, results)
+
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("expected probes to return once all had failed, took %s", elapsed)
+	}
+
+	if results.Status != nil || results.LegacyStatus != nil || results.Query != nil {
+		t.Errorf("expected no results to be stored, got %+v", results)
+	}
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+var (
+	planCache  *MemoryCache[*Plan] = NewMemoryCache[*Plan](time.Minute)
+	usageCache *MemoryCache[*ApplicationUsage]
+)
+
+// SetupQuotas creates the cache of application usage, which is kept for the configured usage cache duration.
+func SetupQuotas() {
+	usageCache = NewMemoryCache[*ApplicationUsage](config.Quotas.UsageCacheDuration)
+}
+
+// QuotaPeriod is the usage of an application during the current day or month. Fresh fetches and cache hits are weighted
+// by the plan of the application to calculate the usage that counts towards the quota. A quota of 0 is unlimited.
+type QuotaPeriod struct {
+	Start     time.Time `json:"start"`
+	End       time.Time `json:"end"`
+	Requests  int64     `json:"requests"`
+	CacheHits int64     `json:"cacheHits"`
+	Usage     float64   `json:"usage"`
+	Quota     int64     `json:"quota"`
+	Remaining *float64  `json:"remaining"`
+}
+
+// Exceeded returns whether the usage has reached the quota of the period.
+func (p QuotaPeriod) Exceeded() bool {
+	return p.Quota > 0 && p.Usage >= float64(p.Quota)
+}
+
+// QuotaUsage is the plan of an application and its usage during the current day and month.
+type QuotaUsage struct {
+	Plan    *Plan       `json:"plan"`
+	Daily   QuotaPeriod `json:"daily"`
+	Monthly QuotaPeriod `json:"monthly"`
+}
+
+// GetApplicationPlan returns the plan of the application, or the default plan if the application does not have one.
+// Nil is returned if the plan does not exist.
+func GetApplicationPlan(ctx context.Context, application *Application) (*Plan, error) {
+	id := config.Quotas.DefaultPlan
+
+	if application != nil && len(application.Plan) > 0 {
+		id = application.Plan
+	}
+
+	if len(id) < 1 {
+		return nil, nil
+	}
+
+	return planCache.Get(id, func() (*Plan, error) {
+		return db.WithContext(ctx).GetPlanByID(id)
+	})
+}
+
+// GetQuotaUsage returns the plan of the application and its usage during the current day and month, which is kept in
+// memory for the usage cache duration.
+func GetQuotaUsage(ctx context.Context, id string) (*QuotaUsage, error) {
+	application, err := GetCachedApplication(ctx, id)
+
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := GetApplicationPlan(ctx, application)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		now        time.Time = time.Now().UTC()
+		dayStart   time.Time = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+		monthStart time.Time = time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	// The start of the day is part of the key so that usage from the previous day or month is never served after it ends
+	usage, err := usageCache.Get(fmt.Sprintf("%s:%d", id, dayStart.Unix()), func() (*ApplicationUsage, error) {
+		return db.WithContext(ctx).GetApplicationUsage(id, dayStart, monthStart)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	result := &QuotaUsage{
+		Plan:    plan,
+		Daily:   NewQuotaPeriod(plan, dayStart, dayStart.AddDate(0, 0, 1), usage.DailyRequests, usage.DailyCacheHits),
+		Monthly: NewQuotaPeriod(plan, monthStart, monthStart.AddDate(0, 1, 0), usage.MonthlyRequests, usage.MonthlyCacheHits),
+	}
+
+	if plan != nil {
+		result.Daily.Quota = plan.DailyQuota
+		result.Monthly.Quota = plan.MonthlyQuota
+	}
+
+	for _, period := range []*QuotaPeriod{&result.Daily, &result.Monthly} {
+		if period.Quota > 0 {
+			period.Remaining = PointerOf(math.Max(float64(period.Quota)-period.Usage, 0))
+		}
+	}
+
+	return result, nil
+}
+
+// NewQuotaPeriod returns the usage of the period, weighting fresh fetches and cache hits by the plan. Requests that are
+// not status requests count as fresh fetches.
+func NewQuotaPeriod(plan *Plan, start, end time.Time, requests, cacheHits int64) QuotaPeriod {
+	freshFetchWeight, cacheHitWeight := 1.0, 1.0
+
+	if plan != nil && plan.FreshFetchWeight != nil {
+		freshFetchWeight = *plan.FreshFetchWeight
+	}
+
+	if plan != nil && plan.CacheHitWeight != nil {
+		cacheHitWeight = *plan.CacheHitWeight
+	}
+
+	return QuotaPeriod{
+		Start:     start,
+		End:       end,
+		Requests:  requests,
+		CacheHits: cacheHits,
+		Usage:     float64(requests-cacheHits)*freshFetchWeight + float64(cacheHits)*cacheHitWeight,
+		Quota:     0,
+		Remaining: nil,
+	}
+}
+
+// CheckQuota rejects the request if the application of the token has used its daily or monthly quota, and warns in the
+// X-Quota-Warning header once the usage reaches the soft limit of the plan.
+func CheckQuota(ctx *fiber.Ctx, token *Token) (bool, error) {
+	if !config.Quotas.Enable {
+		return true, nil
+	}
+
+	usage, err := GetQuotaUsage(ctx.UserContext(), token.Application)
+
+	if err != nil {
+		return false, err
+	}
+
+	if usage.Plan == nil {
+		return true, nil
+	}
+
+	for _, period := range []struct {
+		Name string
+		QuotaPeriod
+	}{{"Daily", usage.Daily}, {"Monthly", usage.Monthly}} {
+		if period.Exceeded() {
+			ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(int64(math.Ceil(time.Until(period.End).Seconds())), 10))
+
+			return false, ctx.Status(http.StatusTooManyRequests).SendString(fmt.Sprintf("%s quota of %d requests for the '%s' plan has been used, it resets at %s", period.Name, period.Quota, usage.Plan.Name, period.End.Format(time.RFC3339)))
+		}
+
+		if period.Quota > 0 && usage.Plan.SoftLimit > 0 && period.Usage >= float64(period.Quota)*usage.Plan.SoftLimit {
+			ctx.Set("X-Quota-Warning", fmt.Sprintf("%s quota is %.0f%% used (%.0f of %d requests)", period.Name, period.Usage/float64(period.Quota)*100, period.Usage, period.Quota))
+		}
+	}
+
+	return true, nil
+}
+
+// UsageMiddleware records the authenticated requests that were served from the cache, so that plans can weight cache
+// hits differently from fresh fetches.
+func UsageMiddleware(ctx *fiber.Ctx) error {
+	err := ctx.Next()
+
+	token := GetRequestToken(ctx)
+
+	if token == nil || ctx.GetRespHeader("X-Cache-Hit") != "true" {
+		return err
+	}
+
+	if logErr := db.WithContext(ctx.UserContext()).UpsertRequestLog(
+		bson.M{
+			"application": token.Application,
+			"timestamp":   GetStartOfHour(),
+			"token":       token.ID,
+		},
+		bson.M{
+			"$setOnInsert": bson.M{
+				"_id": RandomHexString(16),
+			},
+			"$inc": bson.M{
+				"cacheHitCount": 1,
+			},
+		},
+	); logErr != nil {
+		slog.ErrorContext(ctx.UserContext(), "Failed to record cache hit usage", "error", logErr, "application", token.Application)
+	}
+
+	return err
+}
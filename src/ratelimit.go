@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	// slidingWindowScript records a request in the sorted set of request times within the window, unless the limit has
+	// already been reached. It returns whether the request was allowed, the number of requests in the window, and the
+	// number of milliseconds until the oldest request leaves the window.
+	slidingWindowScript *redis.Script = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+
+	count = count + 1
+	allowed = 1
+end
+
+redis.call('PEXPIRE', KEYS[1], window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+
+return {allowed, count, reset}
+`)
+	applicationCache *MemoryCache[*Application] = NewMemoryCache[*Application](time.Minute)
+)
+
+// RateLimit is the limit that applies to a request, and the key of the window that it is counted in.
+type RateLimit struct {
+	Key    string
+	Limit  int64
+	Window time.Duration
+}
+
+// RateLimitResult is the state of a rate limit window after a request was counted in it.
+type RateLimitResult struct {
+	Allowed   bool
+	Remaining int64
+	Reset     time.Duration
+}
+
+// RateLimitMiddleware counts every request against the rate limit of its token, application or client IP address, and
+// rejects it if the limit has been reached. The state of the limit is sent in the RateLimit headers of every response.
+func RateLimitMiddleware(ctx *fiber.Ctx) error {
+	limit := GetRateLimit(ctx)
+
+	result, err := CheckRateLimit(ctx.UserContext(), limit)
+
+	if err != nil {
+		// Requests are allowed when the limit cannot be checked, rather than failing every request while Redis is unavailable
+		slog.WarnContext(ctx.UserContext(), "Failed to check rate limit", "error", err, "key", limit.Key)
+
+		return ctx.Next()
+	}
+
+	reset := int64(math.Ceil(result.Reset.Seconds()))
+
+	ctx.Set("RateLimit-Limit", strconv.FormatInt(limit.Limit, 10))
+	ctx.Set("RateLimit-Remaining", strconv.FormatInt(result.Remaining, 10))
+	ctx.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	ctx.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit.Limit, int64(limit.Window.Seconds())))
+
+	if !result.Allowed {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.FormatInt(reset, 10))
+
+		return ctx.Status(http.StatusTooManyRequests).SendString(fmt.Sprintf("Rate limit exceeded, please try again in %d seconds", reset))
+	}
+
+	return ctx.Next()
+}
+
+// GetRateLimit returns the rate limit that applies to the request. Requests with a valid token are limited per token or
+// application using the limit of the application, and all other requests are limited per client IP address.
+func GetRateLimit(ctx *fiber.Ctx) *RateLimit {
+	result := &RateLimit{
+		Key:    fmt.Sprintf("rate-limit:ip:%s", ctx.IP()),
+		Limit:  config.RateLimit.AnonymousLimit,
+		Window: config.RateLimit.Window,
+	}
+
+	token, err := LookupRequestToken(ctx)
+
+	if err != nil {
+		slog.WarnContext(ctx.UserContext(), "Failed to look up token for rate limit", "error", err)
+
+		return result
+	}
+
+	if token == nil {
+		return result
+	}
+
+	switch config.RateLimit.KeyBy {
+	case "token":
+		result.Key = fmt.Sprintf("rate-limit:token:%s", token.ID)
+	default:
+		result.Key = fmt.Sprintf("rate-limit:application:%s", token.Application)
+	}
+
+	result.Limit = config.RateLimit.Limit
+
+	application, err := GetCachedApplication(ctx.UserContext(), token.Application)
+
+	if err != nil {
+		slog.WarnContext(ctx.UserContext(), "Failed to look up application for rate limit", "error", err, "application", token.Application)
+
+		return result
+	}
+
+	if application != nil && application.RateLimit != nil {
+		if application.RateLimit.Limit > 0 {
+			result.Limit = application.RateLimit.Limit
+		}
+
+		if application.RateLimit.Window > 0 {
+			result.Window = time.Duration(application.RateLimit.Window) * time.Second
+		}
+	}
+
+	return result
+}
+
+// CheckRateLimit counts a request in the sliding window of the rate limit, unless the limit has already been reached.
+func CheckRateLimit(ctx context.Context, limit *RateLimit) (*RateLimitResult, error) {
+	now := time.Now().UnixMilli()
+
+	value, err := r.WithContext(ctx).RunScript(
+		slidingWindowScript,
+		[]string{limit.Key},
+		now,
+		limit.Window.Milliseconds(),
+		limit.Limit,
+		fmt.Sprintf("%d-%s", now, RandomHexString(8)),
+	)
+
+	if err != nil {
+		return nil, err
+	}
+
+	values, ok := value.([]interface{})
+
+	if !ok || len(values) != 3 {
+		return nil, fmt.Errorf("unexpected rate limit script result: %v", value)
+	}
+
+	allowed, _ := values[0].(int64)
+	count, _ := values[1].(int64)
+	reset, _ := values[2].(int64)
+
+	return &RateLimitResult{
+		Allowed:   allowed == 1,
+		Remaining: max(limit.Limit-count, 0),
+		Reset:     time.Duration(reset) * time.Millisecond,
+	}, nil
+}
+
+// GetCachedApplication returns the application with the ID, using a copy kept in memory for a short time so that it is
+// not retrieved from MongoDB on every request.
+func GetCachedApplication(ctx context.Context, id string) (*Application, error) {
+	return applicationCache.Get(id, func() (*Application, error) {
+		return db.WithContext(ctx).GetApplicationByID(id)
+	})
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"errors"
+	"log/slog"
 	"time"
 
 	"github.com/go-redsync/redsync/v4"
@@ -11,13 +12,17 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-const defaultTimeout = 5 * time.Second
+const (
+	defaultTimeout = 5 * time.Second
+	lockExpiry     = 8 * time.Second
+)
 
 // Redis is a wrapper around the Redis client.
 type Redis struct {
 	Client     *redis.Client
 	Pool       *redsyncredis.Pool
 	SyncClient *redsync.Redsync
+	ctx        context.Context
 }
 
 // Connect establishes a connection to the Redis server using the configuration.
@@ -37,6 +42,8 @@ func (r *Redis) Connect() error {
 	}
 
 	r.Client = redis.NewClient(opts)
+	r.Client.AddHook(redisMetricsHook{})
+	r.Client.AddHook(redisTracingHook{})
 
 	if err = r.Client.Ping(ctx).Err(); err != nil {
 		return err
@@ -49,13 +56,30 @@ func (r *Redis) Connect() error {
 	return nil
 }
 
+// WithContext returns a copy of the client that uses the context as the parent of every call, so that calls are traced
+// as part of the request they were made for.
+func (r *Redis) WithContext(ctx context.Context) *Redis {
+	result := *r
+	result.ctx = ctx
+
+	return &result
+}
+
+func (r *Redis) context() context.Context {
+	if r.ctx == nil {
+		return context.Background()
+	}
+
+	return r.ctx
+}
+
 // Get retrieves the value and TTL for a given key.
 func (r *Redis) Get(key string) ([]byte, time.Duration, error) {
 	if r.Client == nil {
 		return nil, 0, nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
 
 	defer cancel()
 
@@ -83,39 +107,295 @@ func (r *Redis) Set(key string, value interface{}, ttl time.Duration) error {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
 
 	defer cancel()
 
 	return r.Client.Set(ctx, key, value, ttl).Err()
 }
 
+// SetNX sets the value and TTL for a given key only if it does not already exist, and returns whether it was set.
+func (r *Redis) SetNX(key string, value interface{}, ttl time.Duration) (bool, error) {
+	if r.Client == nil {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.SetNX(ctx, key, value, ttl).Result()
+}
+
+// Expire sets the TTL for a given key.
+func (r *Redis) Expire(key string, ttl time.Duration) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.Expire(ctx, key, ttl).Err()
+}
+
+// Delete removes the given keys.
+func (r *Redis) Delete(keys ...string) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.Del(ctx, keys...).Err()
+}
+
 // Increment increments the integer value of a key by 1.
 func (r *Redis) Increment(key string) error {
 	if r.Client == nil {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), defaultTimeout)
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
 
 	defer cancel()
 
 	return r.Client.Incr(ctx, key).Err()
 }
 
+// IncrementWithTTL increments the integer value of a key by 1, resets its TTL, and returns the new value.
+func (r *Redis) IncrementWithTTL(key string, ttl time.Duration) (int64, error) {
+	if r.Client == nil {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	p := r.Client.Pipeline()
+
+	value := p.Incr(ctx, key)
+	p.Expire(ctx, key, ttl)
+
+	if _, err := p.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return value.Val(), nil
+}
+
+// Decrement decrements the integer value of a key by 1 and returns the new value.
+func (r *Redis) Decrement(key string) (int64, error) {
+	if r.Client == nil {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.Decr(ctx, key).Result()
+}
+
+// Publish sends the message to every subscriber of the channel.
+func (r *Redis) Publish(channel string, message interface{}) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.Publish(ctx, channel, message).Err()
+}
+
+// Subscribe listens for messages published to the channels, until the returned subscription is closed. Nil is returned
+// if there is no Redis connection.
+func (r *Redis) Subscribe(channels ...string) *redis.PubSub {
+	if r.Client == nil {
+		return nil
+	}
+
+	return r.Client.Subscribe(context.Background(), channels...)
+}
+
+// SetAdd adds the members to the set stored at each key, creating any set that does not exist.
+func (r *Redis) SetAdd(sets map[string][]string) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	p := r.Client.Pipeline()
+
+	for key, members := range sets {
+		if len(members) < 1 {
+			continue
+		}
+
+		p.SAdd(ctx, key, Map(members, func(v string) interface{} { return v })...)
+	}
+
+	_, err := p.Exec(ctx)
+
+	return err
+}
+
+// SetIsMember returns whether each of the members is in the set stored at the key.
+func (r *Redis) SetIsMember(key string, members ...string) ([]bool, error) {
+	if r.Client == nil || len(members) < 1 {
+		return make([]bool, len(members)), nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.SMIsMember(ctx, key, Map(members, func(v string) interface{} { return v })...).Result()
+}
+
+// SetMembers returns the members of the set stored at each key, with an empty list for any key that does not exist.
+func (r *Redis) SetMembers(keys ...string) ([][]string, error) {
+	if r.Client == nil || len(keys) < 1 {
+		return make([][]string, len(keys)), nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	p := r.Client.Pipeline()
+
+	cmds := make([]*redis.StringSliceCmd, len(keys))
+
+	for i, key := range keys {
+		cmds[i] = p.SMembers(ctx, key)
+	}
+
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, err
+	}
+
+	return Map(cmds, func(v *redis.StringSliceCmd) []string { return v.Val() }), nil
+}
+
+// Scan calls the function with each batch of keys matching the pattern, iterating over the keyspace with SCAN so that the
+// server is not blocked while every key is checked.
+func (r *Redis) Scan(pattern string, fn func(keys []string) error) error {
+	if r.Client == nil {
+		return nil
+	}
+
+	var cursor uint64 = 0
+
+	for {
+		ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+		keys, next, err := r.Client.Scan(ctx, cursor, pattern, 1000).Result()
+
+		cancel()
+
+		if err != nil {
+			return err
+		}
+
+		if len(keys) > 0 {
+			if err = fn(keys); err != nil {
+				return err
+			}
+		}
+
+		if cursor = next; cursor == 0 {
+			return nil
+		}
+	}
+}
+
+// RunScript runs the Lua script with the keys and arguments, loading it into the script cache of the server if it is not
+// already there, and returns the result of the script.
+func (r *Redis) RunScript(script *redis.Script, keys []string, args ...interface{}) (interface{}, error) {
+	if r.Client == nil {
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return script.Run(ctx, r.Client, keys, args...).Result()
+}
+
+// Inspect returns the TTL and value size of each key, with a TTL of -2 for any key that does not exist.
+func (r *Redis) Inspect(keys ...string) ([]time.Duration, []int64, error) {
+	if r.Client == nil {
+		return nil, nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	p := r.Client.Pipeline()
+
+	ttlCmds := make([]*redis.DurationCmd, len(keys))
+	sizeCmds := make([]*redis.IntCmd, len(keys))
+
+	for i, key := range keys {
+		ttlCmds[i] = p.TTL(ctx, key)
+		sizeCmds[i] = p.StrLen(ctx, key)
+	}
+
+	if _, err := p.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, nil, err
+	}
+
+	ttls := make([]time.Duration, len(keys))
+	sizes := make([]int64, len(keys))
+
+	for i := range keys {
+		ttls[i] = ttlCmds[i].Val()
+		sizes[i] = sizeCmds[i].Val()
+	}
+
+	return ttls, sizes, nil
+}
+
 // NewMutex creates a new mutually exclusive lock that only one process can hold.
 func (r *Redis) NewMutex(name string) *Mutex {
 	if r.Client == nil || r.SyncClient == nil {
 		return &Mutex{
 			Mutex: nil,
+			ctx:   r.context(),
 		}
 	}
 
 	return &Mutex{
-		Mutex: r.SyncClient.NewMutex(name),
+		Mutex: r.SyncClient.NewMutex(name, redsync.WithExpiry(lockExpiry)),
+		ctx:   r.context(),
 	}
 }
 
+// Ping checks that the Redis server is reachable.
+func (r *Redis) Ping() error {
+	if r.Client == nil {
+		return errors.New("redis is not connected")
+	}
+
+	ctx, cancel := context.WithTimeout(r.context(), defaultTimeout)
+
+	defer cancel()
+
+	return r.Client.Ping(ctx).Err()
+}
+
 // Close closes the Redis client connection.
 func (r *Redis) Close() error {
 	if r.Client == nil {
@@ -128,6 +408,7 @@ func (r *Redis) Close() error {
 // Mutex is a mutually exclusive lock held across all processes.
 type Mutex struct {
 	Mutex *redsync.Mutex
+	ctx   context.Context
 }
 
 // Lock will lock the mutex so no other process can hold it.
@@ -136,13 +417,73 @@ func (m *Mutex) Lock() error {
 		return nil
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second*5)
+	ctx, cancel := context.WithTimeout(m.ctx, defaultTimeout)
 
 	defer cancel()
 
 	return m.Mutex.LockContext(ctx)
 }
 
+// TryLock will attempt to lock the mutex once without waiting, and returns whether the lock was obtained. An error is
+// only returned if the state of the lock could not be determined.
+func (m *Mutex) TryLock() (bool, error) {
+	if m.Mutex == nil {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(m.ctx, defaultTimeout)
+
+	defer cancel()
+
+	err := m.Mutex.TryLockContext(ctx)
+
+	if err == nil {
+		return true, nil
+	}
+
+	var errTaken *redsync.ErrTaken
+
+	if errors.As(err, &errTaken) || errors.Is(err, redsync.ErrFailed) {
+		return false, nil
+	}
+
+	return false, err
+}
+
+// KeepAlive periodically extends the expiration of a held lock, until the returned function is called to release it.
+func (m *Mutex) KeepAlive() func() error {
+	if m.Mutex == nil {
+		return func() error { return nil }
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(lockExpiry / 2)
+
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if ok, err := m.Mutex.Extend(); err != nil || !ok {
+					slog.Error("Failed to extend lock", "error", err, "name", m.Mutex.Name())
+
+					return
+				}
+			}
+		}
+	}()
+
+	return func() error {
+		close(done)
+
+		return m.Unlock()
+	}
+}
+
 // Unlock will allow any other process to obtain a lock with the same key.
 func (m *Mutex) Unlock() error {
 	if m.Mutex == nil {
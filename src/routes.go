@@ -1,28 +1,44 @@
 package main
 
 import (
+	"bufio"
 	"context"
 	"fmt"
+	"log/slog"
 	"main/src/assets"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gofiber/contrib/websocket"
 	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/gofiber/fiber/v2/middleware/favicon"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
 	"github.com/mcstatus-io/mcutil/v4/options"
 	"github.com/mcstatus-io/mcutil/v4/util"
 	"github.com/mcstatus-io/mcutil/v4/vote"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
-func init() {
+// RegisterRoutes adds the middleware enabled in the config and every route to the application.
+func RegisterRoutes() {
+	app.Use(RequestMiddleware)
+
 	app.Use(recover.New(recover.Config{
 		EnableStackTrace: true,
 	}))
 
+	if config.Metrics.Enable {
+		app.Use(MetricsMiddleware)
+	}
+
+	if config.Tracing.Enable {
+		app.Use(TracingMiddleware)
+	}
+
 	app.Use(favicon.New(favicon.Config{
 		Data: assets.Favicon,
 	}))
@@ -30,22 +46,47 @@ func init() {
 	if config.Environment == "development" {
 		app.Use(cors.New(cors.Config{
 			AllowOrigins:  "*",
-			AllowMethods:  "HEAD,OPTIONS,GET,POST",
-			ExposeHeaders: "X-Cache-Hit,X-Cache-Time-Remaining",
+			AllowMethods:  "HEAD,OPTIONS,GET,POST,DELETE",
+			ExposeHeaders: "X-Cache-Hit,X-Cache-Time-Remaining,X-Cache-Stale,X-Request-ID,RateLimit-Limit,RateLimit-Remaining,RateLimit-Reset,RateLimit-Policy,Retry-After,X-Quota-Warning",
 		}))
+	}
 
-		app.Use(logger.New(logger.Config{
-			Format:     "${time} ${ip}:${port} -> ${status}: ${method} ${path} (${latency})\n",
-			TimeFormat: "2006/01/02 15:04:05",
-		}))
+	if config.RateLimit.Enable && config.Redis != nil {
+		app.Use(RateLimitMiddleware)
+	}
+
+	if config.Quotas.Enable && config.MongoDB != nil {
+		app.Use(UsageMiddleware)
 	}
 
 	app.Get("/ping", PingHandler)
+	app.Get("/health/live", LivenessHandler)
+	app.Get("/health/ready", ReadinessHandler)
+	app.Get("/metrics", MetricsHandler)
+	app.Get("/probe", ProbeHandler)
 	app.Get("/status/java/:address", JavaStatusHandler)
 	app.Get("/status/bedrock/:address", BedrockStatusHandler)
+	app.Get("/stream/java/:address", JavaStreamHandler)
+	app.Get("/stream/bedrock/:address", BedrockStreamHandler)
+	app.Get("/blocked/changes", BlocklistChangesHandler)
+	app.Get("/blocked/:address", BlockedHandler)
 	app.Get("/icon", DefaultIconHandler)
 	app.Get("/icon/:address", IconHandler)
 	app.Post("/vote", SendVoteHandler)
+	app.Get("/usage", UsageHandler)
+	app.Get("/monitors", MonitorsHandler)
+	app.Post("/monitors", CreateMonitorHandler)
+	app.Delete("/monitors/:id", DeleteMonitorHandler)
+	app.Get("/webhooks", WebhooksHandler)
+	app.Post("/webhooks", CreateWebhookHandler)
+	app.Delete("/webhooks/:id", DeleteWebhookHandler)
+	app.Get("/webhooks/:id/deliveries", WebhookDeliveriesHandler)
+	app.Post("/webhooks/:id/test", TestWebhookHandler)
+	app.Get("/history/:edition/:address", HistoryHandler)
+	app.Get("/history/:edition/:address/uptime", UptimeHandler)
+	app.Get("/admin/cache/:edition/:address", CacheEntriesHandler)
+	app.Delete("/admin/cache/:edition/:address", PurgeCacheHandler)
+	app.Post("/admin/blocklist/refresh", RefreshBlocklistHandler)
 }
 
 // PingHandler responds with a 200 OK status for simple health checks.
@@ -53,12 +94,71 @@ func PingHandler(ctx *fiber.Ctx) error {
 	return ctx.SendStatus(http.StatusOK)
 }
 
+// LivenessHandler responds with a 200 OK status as long as the process is able to handle requests.
+func LivenessHandler(ctx *fiber.Ctx) error {
+	return ctx.JSON(fiber.Map{"status": HealthStatusOK})
+}
+
+// ReadinessHandler runs the readiness checks, and responds with a 503 Service Unavailable status if any critical check
+// failed so that traffic is routed to other instances.
+func ReadinessHandler(ctx *fiber.Ctx) error {
+	report := RunHealthChecks()
+
+	if report.Status == HealthStatusFail {
+		ctx.Status(http.StatusServiceUnavailable)
+	}
+
+	return ctx.JSON(report)
+}
+
+// MetricsHandler returns the metrics of this instance in the Prometheus text format.
+func MetricsHandler(ctx *fiber.Ctx) error {
+	if authorized, err := AuthenticateMetrics(ctx); err != nil || !authorized {
+		return err
+	}
+
+	return adaptor.HTTPHandler(promhttp.HandlerFor(metricsRegistry, promhttp.HandlerOpts{}))(ctx)
+}
+
+// ProbeHandler fetches a fresh status of the target server using the requested module, and returns the result as
+// gauges in the Prometheus text format. The route bypasses the cache, so it is only available once a metrics token is
+// configured.
+func ProbeHandler(ctx *fiber.Ctx) error {
+	if len(config.Metrics.Tokens) < 1 {
+		return ctx.Status(http.StatusForbidden).SendString("The probe route requires a metrics token to be configured")
+	}
+
+	if authorized, err := AuthenticateMetrics(ctx); err != nil || !authorized {
+		return err
+	}
+
+	module, ok := config.Probe.Modules[ctx.Query("module", "default")]
+
+	if !ok {
+		return ctx.Status(http.StatusBadRequest).SendString(fmt.Sprintf("Unknown module: %s", ctx.Query("module", "default")))
+	}
+
+	edition, hostname, port, err := ParseEditionAddress(ctx.Query("edition", "java"), ctx.Query("target"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid 'target' or 'edition' query parameter")
+	}
+
+	result, err := ProbeTarget(edition, hostname, port, module)
+
+	if err != nil {
+		return err
+	}
+
+	return adaptor.HTTPHandler(promhttp.HandlerFor(NewProbeRegistry(result), promhttp.HandlerOpts{}))(ctx)
+}
+
 // JavaStatusHandler returns the status of the Java edition Minecraft server specified in the address parameter.
 func JavaStatusHandler(ctx *fiber.Ctx) error {
 	opts, err := GetStatusOptions(ctx)
 
 	if err != nil {
-		return err
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
 	}
 
 	hostname, port, err := ParseAddress(strings.ToLower(ctx.Params("address")), util.DefaultJavaPort)
@@ -75,23 +175,23 @@ func JavaStatusHandler(ctx *fiber.Ctx) error {
 		return err
 	}
 
-	if err = r.Increment(fmt.Sprintf("java-hits:%s", fmt.Sprintf("%s:%d", hostname, port))); err != nil {
+	if err = r.WithContext(ctx.UserContext()).Increment(fmt.Sprintf("java-hits:%s", fmt.Sprintf("%s:%d", hostname, port))); err != nil {
 		return err
 	}
 
-	response, expiresAt, err := GetJavaStatus(hostname, port, opts)
-
-	if err != nil {
+	if err = RecordKnownBlocklistServer(ctx.UserContext(), hostname, port); err != nil {
 		return err
 	}
 
-	ctx.Set("X-Cache-Hit", strconv.FormatBool(expiresAt != 0))
+	response, cacheStatus, err := GetJavaStatus(hostname, port, opts)
 
-	if expiresAt != 0 {
-		ctx.Set("X-Cache-Time-Remaining", strconv.Itoa(int(expiresAt.Seconds())))
+	if err != nil {
+		return err
 	}
 
-	return ctx.JSON(response)
+	SetCacheHeaders(ctx, cacheStatus)
+
+	return SendStatusResponse(ctx, response, cacheStatus)
 }
 
 // BedrockStatusHandler returns the status of the Bedrock edition Minecraft server specified in the address parameter.
@@ -99,7 +199,7 @@ func BedrockStatusHandler(ctx *fiber.Ctx) error {
 	opts, err := GetStatusOptions(ctx)
 
 	if err != nil {
-		return err
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
 	}
 
 	hostname, port, err := ParseAddress(strings.ToLower(ctx.Params("address")), util.DefaultBedrockPort)
@@ -108,23 +208,199 @@ func BedrockStatusHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).SendString("Invalid address value")
 	}
 
-	if err = r.Increment(fmt.Sprintf("bedrock-hits:%s", fmt.Sprintf("%s:%d", hostname, port))); err != nil {
+	if err = r.WithContext(ctx.UserContext()).Increment(fmt.Sprintf("bedrock-hits:%s", fmt.Sprintf("%s:%d", hostname, port))); err != nil {
 		return err
 	}
 
-	response, expiresAt, err := GetBedrockStatus(hostname, port, opts)
+	response, cacheStatus, err := GetBedrockStatus(hostname, port, opts)
 
 	if err != nil {
 		return err
 	}
 
-	ctx.Set("X-Cache-Hit", strconv.FormatBool(expiresAt != 0))
+	SetCacheHeaders(ctx, cacheStatus)
+
+	return SendStatusResponse(ctx, response, cacheStatus)
+}
+
+// JavaStreamHandler streams the status of the Java edition Minecraft server specified in the address parameter.
+func JavaStreamHandler(ctx *fiber.Ctx) error {
+	return StreamHandler(ctx, "java")
+}
+
+// BedrockStreamHandler streams the status of the Bedrock edition Minecraft server specified in the address parameter.
+func BedrockStreamHandler(ctx *fiber.Ctx) error {
+	return StreamHandler(ctx, "bedrock")
+}
+
+// StreamHandler streams the status of the server specified in the address parameter, either over a WebSocket if the
+// request is an upgrade request, or as Server-Sent Events otherwise.
+func StreamHandler(ctx *fiber.Ctx, edition string) error {
+	opts, err := GetStatusOptions(ctx)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	_, hostname, port, err := ParseEditionAddress(edition, ctx.Params("address"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid address value")
+	}
+
+	authorized, err := Authenticate(ctx)
+
+	if err != nil || !authorized {
+		return err
+	}
+
+	client := fmt.Sprintf("ip:%s", ctx.IP())
+
+	if token := GetRequestToken(ctx); token != nil {
+		client = fmt.Sprintf("token:%s", token.ID)
+	}
+
+	acquired, keepAlive, release, err := AcquireStreamSlot(client, edition, hostname, port)
+
+	if err != nil {
+		return err
+	}
+
+	if !acquired {
+		return ctx.Status(http.StatusTooManyRequests).SendString("Too many open streams for this client or server")
+	}
+
+	userCtx := ctx.UserContext()
+
+	if websocket.IsWebSocketUpgrade(ctx) {
+		return websocket.New(func(conn *websocket.Conn) {
+			defer release()
+
+			done := make(chan struct{})
+
+			// Incoming messages are discarded, reading is only used to detect when the connection is closed
+			go func() {
+				defer close(done)
+
+				for {
+					if _, _, err := conn.ReadMessage(); err != nil {
+						return
+					}
+				}
+			}()
+
+			if err := RunStream(edition, hostname, port, opts, done, func(event StreamEvent) error {
+				return conn.WriteJSON(event)
+			}, func() error {
+				return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second*5))
+			}, keepAlive); err != nil {
+				slog.ErrorContext(userCtx, "Failed to stream status", "error", err, "host", hostname, "port", port)
+			}
+		})(ctx)
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/event-stream")
+	ctx.Set(fiber.HeaderCacheControl, "no-cache")
+	ctx.Set(fiber.HeaderConnection, "keep-alive")
+	ctx.Set("X-Accel-Buffering", "no")
+
+	ctx.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer release()
+
+		if err := RunStream(edition, hostname, port, opts, nil, func(event StreamEvent) error {
+			return WriteServerSentEvent(w, event)
+		}, func() error {
+			if _, err := w.WriteString(": heartbeat\n\n"); err != nil {
+				return err
+			}
+
+			return w.Flush()
+		}, keepAlive); err != nil {
+			slog.ErrorContext(userCtx, "Failed to stream status", "error", err, "host", hostname, "port", port)
+		}
+	})
+
+	return nil
+}
+
+// BlockedHandler returns every pattern that the address is checked against in the EULA blocked servers list, and which
+// of them are blocked.
+func BlockedHandler(ctx *fiber.Ctx) error {
+	hostname, _, err := ParseAddress(strings.ToLower(ctx.Params("address")), util.DefaultJavaPort)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid address value")
+	}
+
+	list := blockedServers.Load()
+
+	if list == nil {
+		return ctx.Status(http.StatusServiceUnavailable).SendString("The blocklist has not been retrieved yet")
+	}
+
+	var (
+		blocked  bool               = false
+		matched  []string           = make([]string, 0)
+		patterns []BlocklistPattern = make([]BlocklistPattern, 0)
+	)
+
+	for _, pattern := range GetBlocklistPatterns(hostname) {
+		hash := SHA256(pattern)
+
+		result := BlocklistPattern{
+			Pattern:      pattern,
+			Hash:         hash,
+			Blocked:      list.Hashes.Has(hash),
+			KnownPattern: nil,
+		}
+
+		if knownPattern, ok := blocklistDictionary[hash]; ok {
+			result.KnownPattern = PointerOf(knownPattern)
+		}
+
+		if result.Blocked {
+			blocked = true
+			matched = append(matched, pattern)
+		}
+
+		patterns = append(patterns, result)
+	}
+
+	return ctx.JSON(fiber.Map{
+		"address":    hostname,
+		"blocked":    blocked,
+		"matched":    matched,
+		"patterns":   patterns,
+		"updated_at": list.UpdatedAt,
+	})
+}
+
+// BlocklistChangesHandler returns the changes to the EULA blocked servers list, newest first, with the servers known to
+// match each changed hash.
+func BlocklistChangesHandler(ctx *fiber.Ctx) error {
+	if config.MongoDB == nil {
+		return ctx.Status(http.StatusNotFound).SendString("Blocklist changes are not available on this instance")
+	}
+
+	since, err := ParseTimeQuery(ctx, "since", time.Time{})
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	limit := ctx.QueryInt("limit", 100)
+
+	if limit < 1 || limit > 1000 {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid 'limit' value, must be between 1 and 1000")
+	}
+
+	changes, err := db.WithContext(ctx.UserContext()).GetBlocklistChanges(since, int64(limit))
 
-	if expiresAt != 0 {
-		ctx.Set("X-Cache-Time-Remaining", strconv.Itoa(int(expiresAt.Seconds())))
+	if err != nil {
+		return err
 	}
 
-	return ctx.JSON(response)
+	return ctx.JSON(changes)
 }
 
 // IconHandler returns the server icon for the specified Java edition Minecraft server.
@@ -132,7 +408,7 @@ func IconHandler(ctx *fiber.Ctx) error {
 	opts, err := GetStatusOptions(ctx)
 
 	if err != nil {
-		return err
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
 	}
 
 	hostname, port, err := ParseAddress(strings.ToLower(ctx.Params("address")), util.DefaultJavaPort)
@@ -141,19 +417,33 @@ func IconHandler(ctx *fiber.Ctx) error {
 		return ctx.Status(http.StatusBadRequest).SendString("Invalid address value")
 	}
 
-	icon, expiresAt, err := GetServerIcon(hostname, port, opts)
+	icon, cacheStatus, err := GetServerIcon(hostname, port, opts)
 
 	if err != nil {
 		return err
 	}
 
-	ctx.Set("X-Cache-Hit", strconv.FormatBool(expiresAt != 0))
+	SetCacheHeaders(ctx, cacheStatus)
 
-	if expiresAt != 0 {
-		ctx.Set("X-Cache-Time-Remaining", strconv.Itoa(int(expiresAt.Seconds())))
+	return ctx.Type("png").Send(icon)
+}
+
+// SetCacheHeaders sets the response headers describing the cache state of the response.
+func SetCacheHeaders(ctx *fiber.Ctx, cacheStatus *CacheStatus) {
+	ctx.Set("X-Cache-Hit", strconv.FormatBool(cacheStatus.Hit))
+
+	if cacheStatus.Hit {
+		ctx.Set("X-Cache-Stale", strconv.FormatBool(cacheStatus.Stale))
+		ctx.Set("X-Cache-Time-Remaining", strconv.Itoa(int(cacheStatus.TimeRemaining.Seconds())))
 	}
+}
 
-	return ctx.Type("png").Send(icon)
+// SendStatusResponse sends the JSON encoded status response, adding the fields that are specific to the current request
+// without decoding it.
+func SendStatusResponse(ctx *fiber.Ctx, response []byte, cacheStatus *CacheStatus) error {
+	ctx.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+
+	return ctx.Send(AppendJSONFields(response, map[string]bool{"stale": cacheStatus.Stale}))
 }
 
 // DefaultIconHandler returns the default server icon.
@@ -187,3 +477,517 @@ func SendVoteHandler(ctx *fiber.Ctx) error {
 
 	return ctx.Status(http.StatusOK).SendString("The vote was successfully sent to the server")
 }
+
+// UsageHandler returns the plan of the application and its usage during the current day and month. The request is not
+// counted towards the quota, so that it can still be used once the quota has been reached.
+func UsageHandler(ctx *fiber.Ctx) error {
+	if config.MongoDB == nil {
+		return ctx.Status(http.StatusNotImplemented).SendString("This route requires MongoDB to be configured")
+	}
+
+	token, err := LookupRequestToken(ctx)
+
+	if err != nil {
+		return err
+	}
+
+	if token == nil {
+		return ctx.Status(http.StatusUnauthorized).SendString("Missing or invalid authorization token in 'Authorization' header")
+	}
+
+	usage, err := GetQuotaUsage(ctx.UserContext(), token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(usage)
+}
+
+// MonitorsHandler lists the monitors registered by the application of the current token.
+func MonitorsHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	monitors, err := db.GetMonitorsByApplication(token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(monitors)
+}
+
+// CreateMonitorHandler registers a new monitor for the application of the current token.
+func CreateMonitorHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	opts, err := GetMonitorOptions(ctx)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	_, hostname, port, err := ParseEditionAddress(opts.Edition, opts.Address)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid address value")
+	}
+
+	count, err := db.CountMonitorsByApplication(token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if count >= config.Monitor.MaxPerApplication {
+		return ctx.Status(http.StatusForbidden).SendString(fmt.Sprintf("Applications cannot register more than %d monitors", config.Monitor.MaxPerApplication))
+	}
+
+	monitor := Monitor{
+		ID:           RandomHexString(16),
+		Application:  token.Application,
+		Edition:      opts.Edition,
+		Host:         hostname,
+		Port:         port,
+		Interval:     int64(opts.Interval),
+		Query:        *opts.Query,
+		Timeout:      opts.Timeout,
+		CreatedAt:    time.Now().UTC(),
+		NextPollAt:   GetNextPollTime(time.Now().UTC(), 0),
+		LastPolledAt: nil,
+	}
+
+	if err = db.InsertMonitor(monitor); err != nil {
+		return err
+	}
+
+	return ctx.Status(http.StatusCreated).JSON(monitor)
+}
+
+// DeleteMonitorHandler removes a monitor registered by the application of the current token.
+func DeleteMonitorHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	deleted, err := db.DeleteMonitor(ctx.Params("id"), token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ctx.Status(http.StatusNotFound).SendString("No monitor exists with that ID")
+	}
+
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// WebhooksHandler lists the webhooks registered by the application of the current token.
+func WebhooksHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	webhooks, err := db.GetWebhooksByApplication(token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(webhooks)
+}
+
+// CreateWebhookHandler registers a new webhook for the application of the current token. The response contains the
+// secret used to sign deliveries, which receivers use to verify the signature header.
+func CreateWebhookHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	opts, addresses, err := GetWebhookOptions(ctx)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	count, err := db.CountWebhooksByApplication(token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if count >= config.Webhooks.MaxPerApplication {
+		return ctx.Status(http.StatusForbidden).SendString(fmt.Sprintf("Applications cannot register more than %d webhooks", config.Webhooks.MaxPerApplication))
+	}
+
+	webhook := Webhook{
+		ID:          RandomHexString(16),
+		Application: token.Application,
+		URL:         opts.URL,
+		Format:      opts.Format,
+		Secret:      RandomHexString(32),
+		Addresses:   addresses,
+		Rules:       opts.Rules,
+		Interval:    int64(opts.Interval),
+		Query:       *opts.Query,
+		Timeout:     opts.Timeout,
+		CreatedAt:   time.Now().UTC(),
+		NextPollAt:  GetNextPollTime(time.Now().UTC(), 0),
+	}
+
+	if err = db.InsertWebhook(webhook); err != nil {
+		return err
+	}
+
+	// The secret is only sent when the webhook is created, and is left out when webhooks are listed
+	return ctx.Status(http.StatusCreated).JSON(struct {
+		Webhook
+		Secret string `json:"secret"`
+	}{webhook, webhook.Secret})
+}
+
+// DeleteWebhookHandler removes a webhook registered by the application of the current token.
+func DeleteWebhookHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	deleted, err := db.DeleteWebhook(ctx.Params("id"), token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if !deleted {
+		return ctx.Status(http.StatusNotFound).SendString("No webhook exists with that ID")
+	}
+
+	return ctx.SendStatus(http.StatusNoContent)
+}
+
+// WebhookDeliveriesHandler lists the most recent deliveries of a webhook registered by the application of the current token.
+func WebhookDeliveriesHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	webhook, err := db.GetWebhookByID(ctx.Params("id"), token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if webhook == nil {
+		return ctx.Status(http.StatusNotFound).SendString("No webhook exists with that ID")
+	}
+
+	limit := ctx.QueryInt("limit", 50)
+
+	if limit < 1 || limit > 100 {
+		return ctx.Status(http.StatusBadRequest).SendString("Invalid 'limit' value, must be between 1 and 100")
+	}
+
+	deliveries, err := db.GetWebhookDeliveries(webhook.ID, int64(limit))
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(deliveries)
+}
+
+// TestWebhookHandler sends a single test event for the first address of a webhook and responds with the recorded delivery.
+func TestWebhookHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	webhook, err := db.GetWebhookByID(ctx.Params("id"), token.Application)
+
+	if err != nil {
+		return err
+	}
+
+	if webhook == nil {
+		return ctx.Status(http.StatusNotFound).SendString("No webhook exists with that ID")
+	}
+
+	sample := &StatusSample{
+		Timestamp: time.Now().UTC(),
+		Online:    true,
+	}
+
+	event := WebhookEvent{
+		Type:      "test",
+		Rule:      WebhookRule{Type: "test"},
+		Previous:  sample,
+		Current:   sample,
+		Timestamp: sample.Timestamp,
+	}
+
+	if len(webhook.Addresses) > 0 {
+		event.Address = webhook.Addresses[0]
+	}
+
+	// Test deliveries are attempted once so the request is not held open by the retry backoff
+	delivery, err := DeliverWebhook(*webhook, event, 1)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(delivery)
+}
+
+// HistoryHandler returns the status history recorded by the application's monitor of the server specified in the
+// address parameter, either as raw samples, downsampled to the requested resolution, or the single sample closest to the
+// requested time.
+func HistoryHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	edition, hostname, port, err := ParseEditionAddress(ctx.Params("edition"), ctx.Params("address"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	opts, err := GetHistoryOptions(ctx, time.Hour*24)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	monitor, err := db.GetMonitorByAddress(token.Application, edition, hostname, port)
+
+	if err != nil {
+		return err
+	}
+
+	if monitor == nil {
+		return ctx.Status(http.StatusNotFound).SendString("No monitor exists for this server")
+	}
+
+	if opts.At != nil {
+		sample, err := db.GetClosestStatusSample(monitor.ID, *opts.At)
+
+		if err != nil {
+			return err
+		}
+
+		if sample == nil {
+			return ctx.Status(http.StatusNotFound).SendString("No status history exists for this server")
+		}
+
+		return ctx.JSON(sample)
+	}
+
+	if opts.Resolution == "raw" {
+		samples, err := db.GetStatusSamples(monitor.ID, opts.From, opts.To, maxHistorySamples)
+
+		if err != nil {
+			return err
+		}
+
+		return ctx.JSON(fiber.Map{
+			"host":       hostname,
+			"port":       port,
+			"from":       opts.From,
+			"to":         opts.To,
+			"resolution": opts.Resolution,
+			"samples":    samples,
+		})
+	}
+
+	resolution := historyResolutions[opts.Resolution]
+
+	buckets, err := db.GetStatusHistoryBuckets(monitor.ID, opts.From, opts.To, resolution.Unit, resolution.BinSize)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(fiber.Map{
+		"host":       hostname,
+		"port":       port,
+		"from":       opts.From,
+		"to":         opts.To,
+		"resolution": opts.Resolution,
+		"samples":    buckets,
+	})
+}
+
+// UptimeHandler returns the uptime percentages recorded by the application's monitor of the server specified in the
+// address parameter, along with the outages within the requested time range.
+func UptimeHandler(ctx *fiber.Ctx) error {
+	token, err := AuthenticateApplication(ctx)
+
+	if err != nil || token == nil {
+		return err
+	}
+
+	edition, hostname, port, err := ParseEditionAddress(ctx.Params("edition"), ctx.Params("address"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	opts, err := GetHistoryOptions(ctx, time.Hour*24*30)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	monitor, err := db.GetMonitorByAddress(token.Application, edition, hostname, port)
+
+	if err != nil {
+		return err
+	}
+
+	if monitor == nil {
+		return ctx.Status(http.StatusNotFound).SendString("No monitor exists for this server")
+	}
+
+	now := time.Now().UTC()
+	end := opts.To
+
+	if now.Before(end) {
+		end = now
+	}
+
+	uptime, err := GetUptime(monitor.ID, now)
+
+	if err != nil {
+		return err
+	}
+
+	samples, err := db.GetStatusSamples(monitor.ID, opts.From, opts.To, maxOutageSamples)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(fiber.Map{
+		"host":    hostname,
+		"port":    port,
+		"from":    opts.From,
+		"to":      opts.To,
+		"uptime":  uptime,
+		"outages": GetOutages(samples, end),
+	})
+}
+
+// CacheEntriesHandler lists the cache entries of the server specified in the address parameter.
+func CacheEntriesHandler(ctx *fiber.Ctx) error {
+	authorized, err := AuthenticateAdmin(ctx)
+
+	if err != nil || !authorized {
+		return err
+	}
+
+	edition, hostname, port, err := ParseEditionAddress(ctx.Params("edition"), ctx.Params("address"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	entries, err := GetCacheEntryInfo(edition, hostname, port)
+
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(fiber.Map{
+		"host":    hostname,
+		"port":    port,
+		"entries": entries,
+	})
+}
+
+// PurgeCacheHandler removes the cache entries of the server specified in the address parameter, or only the single entry
+// specified in the key query parameter.
+func PurgeCacheHandler(ctx *fiber.Ctx) error {
+	authorized, err := AuthenticateAdmin(ctx)
+
+	if err != nil || !authorized {
+		return err
+	}
+
+	edition, hostname, port, err := ParseEditionAddress(ctx.Params("edition"), ctx.Params("address"))
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).SendString(err.Error())
+	}
+
+	entries, err := GetCacheEntryInfo(edition, hostname, port)
+
+	if err != nil {
+		return err
+	}
+
+	keys := Map(entries, func(v CacheEntryInfo) string { return v.Key })
+
+	if key := ctx.Query("key"); len(key) > 0 {
+		if !Contains(keys, key) {
+			return ctx.Status(http.StatusNotFound).SendString("The key does not exist or does not belong to the server")
+		}
+
+		keys = []string{key}
+	}
+
+	if len(keys) > 0 {
+		if err = r.Delete(keys...); err != nil {
+			return err
+		}
+	}
+
+	return ctx.JSON(fiber.Map{
+		"host":    hostname,
+		"port":    port,
+		"deleted": keys,
+	})
+}
+
+// RefreshBlocklistHandler retrieves the EULA blocked servers list immediately instead of waiting for the next refresh.
+func RefreshBlocklistHandler(ctx *fiber.Ctx) error {
+	authorized, err := AuthenticateAdmin(ctx)
+
+	if err != nil || !authorized {
+		return err
+	}
+
+	if err = RefreshBlockedServerList(); err != nil {
+		return ctx.Status(http.StatusBadGateway).SendString(fmt.Sprintf("Failed to retrieve blocklist: %v", err))
+	}
+
+	list := blockedServers.Load()
+
+	return ctx.JSON(fiber.Map{
+		"size":       list.Hashes.Len(),
+		"updated_at": list.UpdatedAt,
+	})
+}
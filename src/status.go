@@ -9,26 +9,31 @@ import (
 	"net"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/mcstatus-io/mcutil/v4/formatting"
-	"github.com/mcstatus-io/mcutil/v4/options"
-	"github.com/mcstatus-io/mcutil/v4/query"
 	"github.com/mcstatus-io/mcutil/v4/response"
 	"github.com/mcstatus-io/mcutil/v4/status"
 	"github.com/mcstatus-io/mcutil/v4/util"
+	"go.opentelemetry.io/otel/attribute"
+	"golang.org/x/sync/singleflight"
+)
+
+var (
+	javaStatusGroup    singleflight.Group
+	bedrockStatusGroup singleflight.Group
 )
 
 // BaseStatus is the base response properties for returning any status response from the API.
 type BaseStatus struct {
-	Online      bool    `json:"online"`
-	Host        string  `json:"host"`
-	Port        uint16  `json:"port"`
-	IPAddress   *string `json:"ip_address"`
-	EULABlocked bool    `json:"eula_blocked"`
-	RetrievedAt int64   `json:"retrieved_at"`
-	ExpiresAt   int64   `json:"expires_at"`
+	Online      bool            `json:"online"`
+	Host        string          `json:"host"`
+	Port        uint16          `json:"port"`
+	IPAddress   *string         `json:"ip_address"`
+	EULABlocked bool            `json:"eula_blocked"`
+	EULAMatch   *BlocklistMatch `json:"eula_blocked_match"`
+	RetrievedAt int64           `json:"retrieved_at"`
+	ExpiresAt   int64           `json:"expires_at"`
 }
 
 // JavaStatusResponse is the combined response of the root response and the Java Edition status response.
@@ -119,310 +124,479 @@ type Plugin struct {
 	Version *string `json:"version"`
 }
 
+// JavaProbeResults is the raw results of each of the probes used to retrieve the status of a Java Edition server.
+type JavaProbeResults struct {
+	Status       *response.StatusModern
+	LegacyStatus *response.StatusLegacy
+	Query        *response.QueryFull
+	SRVRecord    *net.SRV
+	IPAddress    *string
+	IPAddresses  []string
+}
+
 // SRVRecord is the result of the SRV lookup performed during status retrieval
 type SRVRecord struct {
 	Host string `json:"host"`
 	Port uint16 `json:"port"`
 }
 
-// GetJavaStatus returns the status response of a Java Edition server, either using cache or fetching a fresh status.
-func GetJavaStatus(hostname string, port uint16, opts *StatusOptions) (*JavaStatusResponse, time.Duration, error) {
-	cacheKey := GetCacheKey(hostname, port, opts)
-
-	// Wait for any other processes to finish fetching the status of this server
-	if config.Cache.EnableLocks {
-		mutex := r.NewMutex(fmt.Sprintf("java-lock:%s", cacheKey))
-		mutex.Lock()
+// GetJavaStatus returns the JSON encoded status response of a Java Edition server, either using cache or fetching a fresh status.
+func GetJavaStatus(hostname string, port uint16, opts *StatusOptions) ([]byte, *CacheStatus, error) {
+	cacheKey := GetCacheKey(hostname, port)
 
-		defer mutex.Unlock()
-	}
-
-	// Fetch the cached status if it exists
+	// Fetch the cached status if it exists, and refresh it in the background if it is stale
 	if !opts.BypassCache {
-		cache, ttl, err := r.Get(fmt.Sprintf("java:%s", cacheKey))
+		entry, cacheStatus, err := GetCachedStatus(fmt.Sprintf("java:%s", cacheKey), config.Cache.JavaStatusStaleDuration, opts)
 
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, err
 		}
 
-		if cache != nil {
-			var response JavaStatusResponse
+		if entry != nil {
+			if cacheStatus.Stale {
+				// Refresh using the same query option as the cached entry so it remains usable for every request
+				refreshOpts := *opts
+				refreshOpts.Query = entry.Query
+
+				RevalidateInBackground(fmt.Sprintf("java:%s", cacheKey), opts.Timeout, func() error {
+					_, err := FetchAndCacheJavaStatus(hostname, port, &refreshOpts, cacheKey)
 
-			err = json.Unmarshal(cache, &response)
+					return err
+				})
+			}
 
-			return &response, ttl, err
+			ObserveCacheLookup("java", cacheStatus)
+
+			return entry.Response(opts.Query), cacheStatus, nil
 		}
 	}
 
-	// Fetch a fresh status from the server itself
-	{
-		response, err := FetchJavaStatus(hostname, port, opts)
+	// Fetch a fresh status from the server itself, shared with identical requests on this instance and other processes
+	result, err := Coalesce(&javaStatusGroup, opts.CoalesceKey(cacheKey), func() (*CacheResult[*StatusCacheEntry], error) {
+		return FetchExclusive(
+			fmt.Sprintf("java-lock:%s", cacheKey),
+			opts,
+			func() (*StatusCacheEntry, *CacheStatus, error) {
+				return GetCachedStatus(fmt.Sprintf("java:%s", cacheKey), config.Cache.JavaStatusStaleDuration, opts)
+			},
+			func() (*StatusCacheEntry, error) {
+				return FetchAndCacheJavaStatus(hostname, port, opts, cacheKey)
+			},
+		)
+	})
 
-		if err != nil {
-			return nil, 0, err
-		}
+	if err != nil {
+		return nil, nil, err
+	}
 
-		data, err := json.Marshal(response)
+	ObserveCacheLookup("java", result.Status)
 
-		if err != nil {
-			return nil, 0, err
-		}
+	return result.Value.Response(opts.Query), result.Status, nil
+}
+
+// FetchAndCacheJavaStatus fetches a fresh status of a Java Edition server and puts it into the cache.
+func FetchAndCacheJavaStatus(hostname string, port uint16, opts *StatusOptions, cacheKey string) (*StatusCacheEntry, error) {
+	results, err := ProbeJavaStatus(hostname, port, opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	basic, err := BuildJavaResponse(hostname, port, results.Status, results.LegacyStatus, nil, results.SRVRecord, results.IPAddress, results.IPAddresses)
 
-		if err := r.Set(fmt.Sprintf("java:%s", cacheKey), data, config.Cache.JavaStatusDuration); err != nil {
-			return nil, 0, err
+	if err != nil {
+		return nil, err
+	}
+
+	var full *JavaStatusResponse = nil
+
+	if opts.Query {
+		if full, err = BuildJavaResponse(hostname, port, results.Status, results.LegacyStatus, results.Query, results.SRVRecord, results.IPAddress, results.IPAddresses); err != nil {
+			return nil, err
 		}
+	}
 
-		return response, 0, nil
+	online := basic.Online || (full != nil && full.Online)
+
+	duration, err := GetStatusCacheDuration(fmt.Sprintf("java-offline:%s", cacheKey), online, config.Cache.JavaStatusDuration, config.Cache.JavaStatusOfflineDuration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &StatusCacheEntry{
+		Query:       opts.Query,
+		RetrievedAt: basic.RetrievedAt,
+		Full:        nil,
+		Basic:       nil,
 	}
-}
 
-// GetBedrockStatus returns the status response of a Bedrock Edition server, either using cache or fetching a fresh status.
-func GetBedrockStatus(hostname string, port uint16, opts *StatusOptions) (*BedrockStatusResponse, time.Duration, error) {
-	cacheKey := GetCacheKey(hostname, port, nil)
+	basic.ExpiresAt = basic.RetrievedAt + duration.Milliseconds()
 
-	// Wait for any other processes to finish fetching the status of this server
-	if config.Cache.EnableLocks {
-		mutex := r.NewMutex(fmt.Sprintf("bedrock-lock:%s", cacheKey))
-		mutex.Lock()
+	if entry.Basic, err = json.Marshal(basic); err != nil {
+		return nil, err
+	}
+
+	if full != nil {
+		full.RetrievedAt = basic.RetrievedAt
+		full.ExpiresAt = basic.ExpiresAt
+
+		if entry.Full, err = json.Marshal(full); err != nil {
+			return nil, err
+		}
+	}
 
-		defer mutex.Unlock()
+	if err = SetCachedStatus(fmt.Sprintf("java:%s", cacheKey), entry, duration, config.Cache.JavaStatusStaleDuration); err != nil {
+		return nil, err
 	}
 
-	// Fetch the cached status if it exists
+	return entry, nil
+}
+
+// GetBedrockStatus returns the JSON encoded status response of a Bedrock Edition server, either using cache or fetching a fresh status.
+func GetBedrockStatus(hostname string, port uint16, opts *StatusOptions) ([]byte, *CacheStatus, error) {
+	cacheKey := GetCacheKey(hostname, port)
+
+	// Fetch the cached status if it exists, and refresh it in the background if it is stale
 	if !opts.BypassCache {
-		cache, ttl, err := r.Get(fmt.Sprintf("bedrock:%s", cacheKey))
+		entry, cacheStatus, err := GetCachedStatus(fmt.Sprintf("bedrock:%s", cacheKey), config.Cache.BedrockStatusStaleDuration, opts)
 
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, err
 		}
 
-		if cache != nil {
-			var response BedrockStatusResponse
+		if entry != nil {
+			if cacheStatus.Stale {
+				RevalidateInBackground(fmt.Sprintf("bedrock:%s", cacheKey), opts.Timeout, func() error {
+					_, err := FetchAndCacheBedrockStatus(hostname, port, opts, cacheKey)
+
+					return err
+				})
+			}
 
-			err = json.Unmarshal(cache, &response)
+			ObserveCacheLookup("bedrock", cacheStatus)
 
-			return &response, ttl, err
+			return entry.Response(opts.Query), cacheStatus, nil
 		}
 	}
 
-	// Fetch a fresh status from the server itself
-	{
-		response, err := FetchBedrockStatus(hostname, port, opts)
+	// Fetch a fresh status from the server itself, shared with identical requests on this instance and other processes
+	result, err := Coalesce(&bedrockStatusGroup, opts.CoalesceKey(cacheKey), func() (*CacheResult[*StatusCacheEntry], error) {
+		return FetchExclusive(
+			fmt.Sprintf("bedrock-lock:%s", cacheKey),
+			opts,
+			func() (*StatusCacheEntry, *CacheStatus, error) {
+				return GetCachedStatus(fmt.Sprintf("bedrock:%s", cacheKey), config.Cache.BedrockStatusStaleDuration, opts)
+			},
+			func() (*StatusCacheEntry, error) {
+				return FetchAndCacheBedrockStatus(hostname, port, opts, cacheKey)
+			},
+		)
+	})
 
-		if err != nil {
-			return nil, 0, err
-		}
+	if err != nil {
+		return nil, nil, err
+	}
 
-		data, err := json.Marshal(response)
+	ObserveCacheLookup("bedrock", result.Status)
 
-		if err != nil {
-			return nil, 0, err
-		}
+	return result.Value.Response(opts.Query), result.Status, nil
+}
 
-		if err = r.Set(fmt.Sprintf("bedrock:%s", cacheKey), data, config.Cache.BedrockStatusDuration); err != nil {
-			return nil, 0, err
-		}
+// FetchAndCacheBedrockStatus fetches a fresh status of a Bedrock Edition server and puts it into the cache.
+func FetchAndCacheBedrockStatus(hostname string, port uint16, opts *StatusOptions, cacheKey string) (*StatusCacheEntry, error) {
+	response, err := FetchBedrockStatus(hostname, port, opts)
+
+	if err != nil {
+		return nil, err
+	}
 
-		return response, 0, nil
+	duration, err := GetStatusCacheDuration(fmt.Sprintf("bedrock-offline:%s", cacheKey), response.Online, config.Cache.BedrockStatusDuration, config.Cache.BedrockStatusOfflineDuration)
+
+	if err != nil {
+		return nil, err
+	}
+
+	response.ExpiresAt = response.RetrievedAt + duration.Milliseconds()
+
+	// Bedrock Edition does not support query, so the status satisfies requests with either option
+	entry := &StatusCacheEntry{
+		Query:       true,
+		RetrievedAt: response.RetrievedAt,
+		Full:        nil,
+		Basic:       nil,
+	}
+
+	if entry.Basic, err = json.Marshal(response); err != nil {
+		return nil, err
+	}
+
+	if err = SetCachedStatus(fmt.Sprintf("bedrock:%s", cacheKey), entry, duration, config.Cache.BedrockStatusStaleDuration); err != nil {
+		return nil, err
 	}
+
+	return entry, nil
 }
 
-// GetServerIcon returns the icon image of a Java Edition server, either using cache or fetching a fresh image.
-func GetServerIcon(hostname string, port uint16, opts *StatusOptions) ([]byte, time.Duration, error) {
-	cacheKey := GetCacheKey(hostname, port, nil)
+// GetServerIcon returns the icon image of a Java Edition server, either using cache or the icon from the Java Edition status.
+func GetServerIcon(hostname string, port uint16, opts *StatusOptions) ([]byte, *CacheStatus, error) {
+	cacheKey := GetCacheKey(hostname, port)
 
-	// Fetch the cached icon if it exists
+	// Fetch the cached icon if it exists, and refresh it in the background if it is stale
 	if !opts.BypassCache {
-		cache, ttl, err := r.Get(fmt.Sprintf("icon:%s", cacheKey))
+		icon, cacheStatus, err := GetCachedServerIcon(StatusContext(opts), cacheKey)
 
 		if err != nil {
-			return nil, 0, err
+			return nil, nil, err
 		}
 
-		if cache != nil {
-			return cache, ttl, err
+		if icon != nil {
+			if cacheStatus.Stale {
+				RevalidateInBackground(fmt.Sprintf("icon:%s", cacheKey), opts.Timeout, func() error {
+					_, err := FetchAndCacheServerIcon(hostname, port, opts, cacheKey)
+
+					return err
+				})
+			}
+
+			ObserveCacheLookup("icon", cacheStatus)
+
+			return icon, cacheStatus, nil
 		}
 	}
 
-	var (
-		icon []byte = nil
-	)
-
-	// Fetch the icon from the server itself
-	{
-		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+	icon, err := FetchAndCacheServerIcon(hostname, port, opts, cacheKey)
 
-		defer cancel()
+	if err != nil {
+		return nil, nil, err
+	}
 
-		status, err := status.Modern(ctx, hostname, port)
+	ObserveCacheLookup("icon", nil)
 
-		if err == nil && status.Favicon != nil && strings.HasPrefix(*status.Favicon, "data:image/png;base64,") {
-			data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*status.Favicon, "data:image/png;base64,"))
+	// The icon itself was not cached, even if the status it was taken from was
+	return icon, &CacheStatus{Hit: false, Stale: false, TimeRemaining: 0}, nil
+}
 
-			if err != nil {
-				return nil, 0, err
-			}
+// GetCachedServerIcon returns the cached icon of a Java Edition server, or nil if it is not in the cache. Icons are cached
+// using the hash of the image, so servers with the same icon share a single copy of the image data.
+func GetCachedServerIcon(ctx context.Context, cacheKey string) ([]byte, *CacheStatus, error) {
+	hash, cacheStatus, err := GetCacheEntry(ctx, fmt.Sprintf("icon:%s", cacheKey), config.Cache.IconStaleDuration)
 
-			icon = data
-		} else {
-			icon = assets.DefaultIcon
-		}
+	if err != nil || hash == nil {
+		return nil, nil, err
 	}
 
-	// Put the icon into the cache for future requests
-	if err := r.Set(fmt.Sprintf("icon:%s", cacheKey), icon, config.Cache.IconDuration); err != nil {
-		return nil, 0, err
+	icon, _, err := r.WithContext(ctx).Get(fmt.Sprintf("icon-data:%s", hash))
+
+	if err != nil || icon == nil {
+		return nil, nil, err
 	}
 
-	return icon, 0, nil
+	return icon, cacheStatus, nil
 }
 
-// FetchJavaStatus fetches fresh information about a Java Edition Minecraft server.
-func FetchJavaStatus(hostname string, port uint16, opts *StatusOptions) (*JavaStatusResponse, error) {
-	var (
-		err                error
-		srvRecord          *net.SRV
-		resolvedHostname   string = hostname
-		ipAddress          *string
-		statusResult       *response.StatusModern
-		legacyStatusResult *response.StatusLegacy
-		queryResult        *response.QueryFull
-		wg                 sync.WaitGroup
-	)
+// FetchAndCacheServerIcon retrieves the icon from the Java Edition status, which is either cached, being fetched by
+// another request, or fetched fresh from the server, and puts the icon into the cache. The status is retrieved with the
+// same options as a status request, so that the icon and status of a server share the cache entry and a single fetch.
+func FetchAndCacheServerIcon(hostname string, port uint16, opts *StatusOptions, cacheKey string) ([]byte, error) {
+	data, _, err := GetJavaStatus(hostname, port, opts)
 
-	// Setup initial wait group deltas
-	{
-		wg.Add(2)
+	if err != nil {
+		return nil, err
+	}
 
-		if opts.Query {
-			wg.Add(1)
-		}
+	var status JavaStatusResponse
+
+	if err = json.Unmarshal(data, &status); err != nil {
+		return nil, err
 	}
 
-	// Lookup the SRV record
-	{
-		srvRecord, err = util.LookupSRV(hostname)
+	icon := assets.DefaultIcon
 
-		if err == nil && srvRecord != nil {
-			resolvedHostname = strings.Trim(srvRecord.Target, ".")
+	if status.JavaStatus != nil && status.Icon != nil && strings.HasPrefix(*status.Icon, "data:image/png;base64,") {
+		data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(*status.Icon, "data:image/png;base64,"))
+
+		if err != nil {
+			return nil, err
 		}
+
+		icon = data
 	}
 
-	// Resolve the connection hostname to an IP address
+	// Put the icon into the cache for future requests
 	{
-		addr, err := net.ResolveIPAddr("ip", resolvedHostname)
+		hash := SHA256(string(icon))
+		duration := config.Cache.IconDuration + config.Cache.IconStaleDuration
+
+		set, err := r.SetNX(fmt.Sprintf("icon-data:%s", hash), icon, duration)
 
-		if err == nil && addr != nil {
-			ipAddress = PointerOf(addr.IP.String())
+		if err != nil {
+			return nil, err
+		}
+
+		if !set {
+			if err = r.Expire(fmt.Sprintf("icon-data:%s", hash), duration); err != nil {
+				return nil, err
+			}
+		}
+
+		if err = SetCacheEntry(fmt.Sprintf("icon:%s", cacheKey), hash, config.Cache.IconDuration, config.Cache.IconStaleDuration); err != nil {
+			return nil, err
 		}
 	}
 
-	statusContext, statusCancel := context.WithTimeout(context.Background(), opts.Timeout)
-	legacyContext, legacyCancel := context.WithTimeout(context.Background(), opts.Timeout)
-	queryContext, queryCancel := context.WithTimeout(context.Background(), opts.Timeout)
+	return icon, nil
+}
 
-	defer statusCancel()
-	defer legacyCancel()
-	defer queryCancel()
+// FetchJavaStatus fetches fresh information about a Java Edition Minecraft server.
+func FetchJavaStatus(hostname string, port uint16, opts *StatusOptions) (*JavaStatusResponse, error) {
+	results, err := ProbeJavaStatus(hostname, port, opts)
 
-	// Retrieve the post-netty rewrite Java Edition status (Minecraft 1.8+)
-	{
-		go func() {
-			statusResult, _ = status.Modern(statusContext, hostname, port, options.StatusModern{
-				EnableSRV:       true,
-				Timeout:         opts.Timeout - time.Millisecond*100,
-				ProtocolVersion: 47,
-				Ping:            false,
-			})
+	if err != nil {
+		return nil, err
+	}
 
-			wg.Done()
+	return BuildJavaResponse(hostname, port, results.Status, results.LegacyStatus, results.Query, results.SRVRecord, results.IPAddress, results.IPAddresses)
+}
 
-			legacyCancel()
+// ProbeJavaStatus resolves the address of a Java Edition server and runs all of its probes, returning their raw results.
+func ProbeJavaStatus(hostname string, port uint16, opts *StatusOptions) (*JavaProbeResults, error) {
+	inFlightProbes.Add(1)
 
-			if opts.Query && queryResult == nil {
-				time.Sleep(time.Millisecond * 250)
+	defer inFlightProbes.Add(-1)
 
-				if queryResult == nil {
-					queryCancel()
-				}
-			}
-		}()
+	var (
+		resolvedHostname string            = hostname
+		results          *JavaProbeResults = &JavaProbeResults{}
+		dnsStart         time.Time         = time.Now()
+	)
+
+	// Lookup the SRV record
+	if !opts.DisableSRV {
+		_, span := StartSpan(StatusContext(opts), "dns srv", attribute.String("minecraft.host", hostname))
+		start := time.Now()
+
+		srvRecord, err := util.LookupSRV(hostname)
+
+		ObserveProbe(StatusContext(opts), "srv", start, err)
+		EndSpan(span, err)
+
+		if err == nil && srvRecord != nil {
+			results.SRVRecord = srvRecord
+			resolvedHostname = strings.Trim(srvRecord.Target, ".")
+		}
 	}
 
-	// Retrieve the pre-netty rewrite Java Edition status (Minecraft 1.7 and below)
+	// Resolve the connection hostname to an IP address
 	{
-		go func() {
-			legacyStatusResult, _ = status.Legacy(legacyContext, hostname, port, options.StatusLegacy{
-				EnableSRV:       true,
-				Timeout:         opts.Timeout - time.Millisecond*100,
-				ProtocolVersion: -1,
-			})
+		_, span := StartSpan(StatusContext(opts), "dns resolve", attribute.String("minecraft.host", resolvedHostname))
 
-			wg.Done()
+		ipAddress, ipAddresses, err := ResolveIPAddresses(resolvedHostname)
 
-			time.Sleep(time.Millisecond * 250)
+		EndSpan(span, err)
 
-			if queryResult == nil {
-				queryCancel()
-			}
-		}()
+		if err == nil {
+			results.IPAddress = ipAddress
+			results.IPAddresses = ipAddresses
+		}
 	}
 
-	// Retrieve the query information (if it is available)
-	if opts.Query {
-		go func() {
-			queryResult, _ = query.Full(queryContext, hostname, port, options.Query{
-				Timeout: opts.Timeout - time.Millisecond*100,
-			})
+	if opts.Trace != nil {
+		opts.Trace.DNSLookup = time.Since(dnsStart)
+
+		if results.IPAddress != nil {
+			connectPort := port
 
-			wg.Done()
-		}()
+			if results.SRVRecord != nil {
+				connectPort = results.SRVRecord.Port
+			}
+
+			opts.Trace.Address = net.JoinHostPort(*results.IPAddress, strconv.Itoa(int(connectPort)))
+		}
 	}
 
-	wg.Wait()
+	RunJavaProbes(hostname, port, opts, results)
 
-	return BuildJavaResponse(hostname, port, statusResult, legacyStatusResult, queryResult, srvRecord, ipAddress)
+	return results, nil
 }
 
 // FetchBedrockStatus fetches a fresh status of a Bedrock Edition server.
 func FetchBedrockStatus(hostname string, port uint16, opts *StatusOptions) (*BedrockStatusResponse, error) {
+	inFlightProbes.Add(1)
+
+	defer inFlightProbes.Add(-1)
+
 	var (
-		ipAddress *string
-		result    *response.StatusBedrock
+		ipAddress   *string
+		ipAddresses []string
+		result      *response.StatusBedrock
+		err         error
 	)
 
 	// Resolve the connection hostname to an IP address
 	{
-		ipAddr, err := net.ResolveIPAddr("ip", hostname)
+		_, span := StartSpan(StatusContext(opts), "dns resolve", attribute.String("minecraft.host", hostname))
+		start := time.Now()
+
+		resolvedAddress, resolvedAddresses, err := ResolveIPAddresses(hostname)
+
+		EndSpan(span, err)
+
+		if err == nil {
+			ipAddress = resolvedAddress
+			ipAddresses = resolvedAddresses
+		}
 
-		if err == nil && ipAddr != nil {
-			ipAddress = PointerOf(ipAddr.IP.String())
+		if opts.Trace != nil {
+			opts.Trace.DNSLookup = time.Since(start)
 		}
 	}
 
 	// Retrieve the Bedrock Edition status
 	{
-		ctx, cancel := context.WithTimeout(context.Background(), opts.Timeout)
+		ctx, span := StartSpan(StatusContext(opts), "probe bedrock", attribute.String("minecraft.host", hostname), attribute.Int("minecraft.port", int(port)))
+		ctx, cancel := context.WithTimeout(ctx, opts.Timeout)
 
 		defer cancel()
 
-		result, _ = status.Bedrock(ctx, hostname, port)
+		start := time.Now()
+
+		result, err = status.Bedrock(ctx, hostname, port)
+
+		ObserveProbe(ctx, "bedrock", start, err)
+
+		if opts.Trace != nil && err == nil {
+			opts.Trace.Status = time.Since(start)
+		}
+
+		span.SetAttributes(attribute.String("probe.outcome", ProbeOutcome(err)))
+
+		EndSpan(span, err)
 	}
 
-	return BuildBedrockResponse(hostname, port, result, ipAddress)
+	return BuildBedrockResponse(hostname, port, result, ipAddress, ipAddresses)
 }
 
 // BuildJavaResponse builds the response data from the status and query information.
-func BuildJavaResponse(hostname string, port uint16, status *response.StatusModern, legacyStatus *response.StatusLegacy, query *response.QueryFull, srvRecord *net.SRV, ipAddress *string) (result *JavaStatusResponse, err error) {
+func BuildJavaResponse(hostname string, port uint16, status *response.StatusModern, legacyStatus *response.StatusLegacy, query *response.QueryFull, srvRecord *net.SRV, ipAddress *string, ipAddresses []string) (result *JavaStatusResponse, err error) {
+	var srvTarget *string = nil
+
+	if srvRecord != nil {
+		srvTarget = PointerOf(strings.Trim(srvRecord.Target, "."))
+	}
+
+	eulaMatch := FindBlocklistMatch(hostname, srvTarget, ipAddresses)
+
 	result = &JavaStatusResponse{
 		BaseStatus: BaseStatus{
 			Online:      false,
 			Host:        hostname,
 			Port:        port,
 			IPAddress:   ipAddress,
-			EULABlocked: IsBlockedAddress(hostname),
+			EULABlocked: eulaMatch != nil,
+			EULAMatch:   eulaMatch,
 			RetrievedAt: time.Now().UnixMilli(),
-			ExpiresAt:   time.Now().Add(config.Cache.JavaStatusDuration).UnixMilli(),
+			ExpiresAt:   time.Now().Add(config.Cache.JavaStatusOfflineDuration).UnixMilli(),
 		},
 		JavaStatus: nil,
 	}
@@ -606,26 +780,34 @@ func BuildJavaResponse(hostname string, port uint16, status *response.StatusMode
 		}
 	}
 
+	if result.Online {
+		result.ExpiresAt = time.Now().Add(config.Cache.JavaStatusDuration).UnixMilli()
+	}
+
 	return
 }
 
 // BuildBedrockResponse builds the response data from the status information.
-func BuildBedrockResponse(hostname string, port uint16, status *response.StatusBedrock, ipAddress *string) (result *BedrockStatusResponse, err error) {
+func BuildBedrockResponse(hostname string, port uint16, status *response.StatusBedrock, ipAddress *string, ipAddresses []string) (result *BedrockStatusResponse, err error) {
+	eulaMatch := FindBlocklistMatch(hostname, nil, ipAddresses)
+
 	result = &BedrockStatusResponse{
 		BaseStatus: BaseStatus{
 			Online:      false,
 			Host:        hostname,
 			Port:        port,
 			IPAddress:   ipAddress,
-			EULABlocked: IsBlockedAddress(hostname),
+			EULABlocked: eulaMatch != nil,
+			EULAMatch:   eulaMatch,
 			RetrievedAt: time.Now().UnixMilli(),
-			ExpiresAt:   time.Now().Add(config.Cache.BedrockStatusDuration).UnixMilli(),
+			ExpiresAt:   time.Now().Add(config.Cache.BedrockStatusOfflineDuration).UnixMilli(),
 		},
 		BedrockStatus: nil,
 	}
 
 	if status != nil {
 		result.Online = true
+		result.ExpiresAt = time.Now().Add(config.Cache.BedrockStatusDuration).UnixMilli()
 
 		result.BedrockStatus = &BedrockStatus{
 			Version:  nil,
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newBenchmarkJavaStatus returns a status similar in size to a popular server, with an icon, sample players and mods.
+func newBenchmarkJavaStatus() *JavaStatusResponse {
+	players := make([]Player, 0, 12)
+
+	for i := 0; i < 12; i++ {
+		players = append(players, Player{
+			UUID:      fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			NameRaw:   fmt.Sprintf("§aPlayer%d", i),
+			NameClean: fmt.Sprintf("Player%d", i),
+			NameHTML:  fmt.Sprintf(`<span><span style="color: #55ff55;">Player%d</span></span>`, i),
+		})
+	}
+
+	mods := make([]Mod, 0, 40)
+
+	for i := 0; i < 40; i++ {
+		mods = append(mods, Mod{Name: fmt.Sprintf("mod%d", i), Version: "1.0.0"})
+	}
+
+	return &JavaStatusResponse{
+		BaseStatus: BaseStatus{
+			Online:      true,
+			Host:        "play.example.com",
+			Port:        25565,
+			IPAddress:   PointerOf("203.0.113.10"),
+			RetrievedAt: time.Now().UnixMilli(),
+			ExpiresAt:   time.Now().Add(time.Minute).UnixMilli(),
+		},
+		SRVRecord: &SRVRecord{Host: "mc.example.com", Port: 25565},
+		JavaStatus: &JavaStatus{
+			Version: &JavaVersion{NameRaw: "Paper 1.21.1", NameClean: "Paper 1.21.1", NameHTML: "<span>Paper 1.21.1</span>", Protocol: 767},
+			Players: JavaPlayers{Online: PointerOf(int64(1234)), Max: PointerOf(int64(5000)), List: players},
+			MOTD: MOTD{
+				Raw:   strings.Repeat("§6Example §bNetwork §7- §aSurvival, Skyblock and more ", 2),
+				Clean: strings.Repeat("Example Network - Survival, Skyblock and more ", 2),
+				HTML:  strings.Repeat(`<span style="color: #ffaa00;">Example</span> <span style="color: #55ffff;">Network</span> `, 2),
+			},
+			Icon: PointerOf("data:image/png;base64," + base64.StdEncoding.EncodeToString([]byte(strings.Repeat("icon", 1500)))),
+			Mods: mods,
+		},
+	}
+}
+
+// BenchmarkCacheHitDecodeEncode measures the previous cache hit path, which decoded the cached JSON into the response
+// struct and encoded it again with the per-request fields.
+func BenchmarkCacheHitDecodeEncode(b *testing.B) {
+	data, err := json.Marshal(newBenchmarkJavaStatus())
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ReportMetric(float64(len(data)), "cached-bytes")
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var response JavaStatusResponse
+
+		if err := json.Unmarshal(data, &response); err != nil {
+			b.Fatal(err)
+		}
+
+		if _, err := json.Marshal(struct {
+			*JavaStatusResponse
+			Stale bool `json:"stale"`
+		}{&response, false}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// BenchmarkCacheHitPreEncoded measures the current cache hit path, which decompresses the cache entry and sends the
+// stored JSON with the per-request fields appended.
+func BenchmarkCacheHitPreEncoded(b *testing.B) {
+	response, err := json.Marshal(newBenchmarkJavaStatus())
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data, err := EncodeStatusCacheEntry(&StatusCacheEntry{
+		RetrievedAt: time.Now().UnixMilli(),
+		Basic:       response,
+	})
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ReportMetric(float64(len(data)), "cached-bytes")
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		entry, err := DecodeStatusCacheEntry(data)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		_ = AppendJSONFields(entry.Response(false), map[string]bool{"stale": false})
+	}
+}
+
+func TestStatusCacheEntryRoundTrip(t *testing.T) {
+	response, err := json.Marshal(newBenchmarkJavaStatus())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := EncodeStatusCacheEntry(&StatusCacheEntry{Query: false, Basic: response})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entry, err := DecodeStatusCacheEntry(data)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := AppendJSONFields(entry.Response(true), map[string]bool{"stale": true})
+
+	var decoded map[string]interface{}
+
+	if err = json.Unmarshal(result, &decoded); err != nil {
+		t.Fatalf("appended response is not valid JSON: %v", err)
+	}
+
+	if decoded["stale"] != true || decoded["host"] != "play.example.com" {
+		t.Errorf("unexpected response fields: stale=%v host=%v", decoded["stale"], decoded["host"])
+	}
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const streamSlotTTL = time.Minute
+
+var (
+	streamHub *StreamHub = &StreamHub{
+		Topics: make(map[string]*StreamTopic),
+	}
+	localStreamSlots *StreamSlots = &StreamSlots{
+		Counts: make(map[string]int64),
+	}
+	// streamIgnoredFields are the fields that change on every refresh, which are only included in a diff event if
+	// any other field has changed.
+	streamIgnoredFields []string = []string{"retrieved_at", "expires_at"}
+)
+
+// StreamHub is the set of status streams that clients of this instance are subscribed to.
+type StreamHub struct {
+	Topics map[string]*StreamTopic
+	Mutex  sync.Mutex
+}
+
+// StreamTopic is the status stream of a single server. Every instance with subscribers listens on the Redis channel of
+// the topic, and one instance at a time holds the lock of the topic and publishes a fresh status on every refresh.
+type StreamTopic struct {
+	Name        string
+	Edition     string
+	Hostname    string
+	Port        uint16
+	Query       bool
+	CacheKey    string
+	Subscribers map[chan []byte]struct{}
+	Done        chan struct{}
+}
+
+// StreamSlots is the number of open stream connections of each client and server on this instance, which limits the
+// connections when Redis is not configured.
+type StreamSlots struct {
+	Counts map[string]int64
+	Mutex  sync.Mutex
+}
+
+// StreamEvent is a single event sent to the client of a status stream.
+type StreamEvent struct {
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Subscribe adds a subscriber to the status stream of the server, starting the stream if this is the first subscriber
+// on this instance. The returned channel receives every status published to the stream, until the returned function is
+// called to unsubscribe.
+func (h *StreamHub) Subscribe(edition, hostname string, port uint16, query bool) (<-chan []byte, func()) {
+	cacheKey := GetCacheKey(hostname, port)
+	name := fmt.Sprintf("stream:%s:%s:%t", edition, cacheKey, query)
+	messages := make(chan []byte, 8)
+
+	h.Mutex.Lock()
+
+	defer h.Mutex.Unlock()
+
+	topic, ok := h.Topics[name]
+
+	if !ok {
+		topic = &StreamTopic{
+			Name:        name,
+			Edition:     edition,
+			Hostname:    hostname,
+			Port:        port,
+			Query:       query,
+			CacheKey:    cacheKey,
+			Subscribers: make(map[chan []byte]struct{}),
+			Done:        make(chan struct{}),
+		}
+
+		h.Topics[name] = topic
+
+		go topic.Run()
+	}
+
+	topic.Subscribers[messages] = struct{}{}
+
+	return messages, func() {
+		h.Mutex.Lock()
+
+		defer h.Mutex.Unlock()
+
+		delete(topic.Subscribers, messages)
+
+		if len(topic.Subscribers) < 1 {
+			close(topic.Done)
+
+			delete(h.Topics, name)
+		}
+	}
+}
+
+// Broadcast sends the status to every subscriber of the topic on this instance. Subscribers that are not keeping up are
+// skipped, which is safe because the next diff is always calculated against the last status they received.
+func (h *StreamHub) Broadcast(topic *StreamTopic, status []byte) {
+	h.Mutex.Lock()
+
+	defer h.Mutex.Unlock()
+
+	for subscriber := range topic.Subscribers {
+		select {
+		case subscriber <- status:
+		default:
+		}
+	}
+}
+
+// Run listens for statuses published to the topic, and periodically attempts to become the instance that refreshes
+// the status of the server, until there are no subscribers remaining on this instance.
+func (t *StreamTopic) Run() {
+	if pubsub := r.Subscribe(t.Name); pubsub != nil {
+		// The subscription is closed as soon as the topic is done, rather than once a refresh in progress returns, so
+		// that it never overlaps with the subscription of a topic re-created with the same name
+		go func() {
+			defer pubsub.Close()
+
+			messages := pubsub.Channel()
+
+			for {
+				select {
+				case <-t.Done:
+					return
+				case message, ok := <-messages:
+					{
+						if !ok {
+							return
+						}
+
+						streamHub.Broadcast(t, []byte(message.Payload))
+					}
+				}
+			}
+		}()
+	}
+
+	var release func() error = nil
+
+	defer func() {
+		if release != nil {
+			if err := release(); err != nil {
+				slog.Error("Failed to release stream lock", "error", err, "topic", t.Name)
+			}
+		}
+	}()
+
+	mutex := r.NewMutex(fmt.Sprintf("%s-lock", t.Name))
+	ticker := time.NewTicker(config.Stream.RefreshInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-t.Done:
+			return
+		case <-ticker.C:
+			{
+				if release == nil {
+					acquired, err := mutex.TryLock()
+
+					if err != nil {
+						slog.Error("Failed to acquire stream lock", "error", err, "topic", t.Name)
+
+						continue
+					}
+
+					if !acquired {
+						continue
+					}
+
+					release = mutex.KeepAlive()
+				}
+
+				if err := t.Refresh(); err != nil {
+					slog.Error("Failed to refresh stream", "error", err, "topic", t.Name)
+				}
+			}
+		}
+	}
+}
+
+// Refresh fetches a fresh status of the server, which also updates the cache, and publishes it to every instance.
+func (t *StreamTopic) Refresh() error {
+	opts := &StatusOptions{
+		Query:   t.Query,
+		Timeout: config.Stream.Timeout,
+	}
+
+	// Refresh with query data if the cached entry has it, so the entry is never replaced by one that cannot be used for
+	// requests with query data
+	if !opts.Query {
+		cached, _, err := GetCachedStatus(fmt.Sprintf("%s:%s", t.Edition, t.CacheKey), 0, opts)
+
+		if err != nil {
+			return err
+		}
+
+		if cached != nil && cached.Query {
+			opts.Query = true
+		}
+	}
+
+	var (
+		entry *StatusCacheEntry
+		err   error
+	)
+
+	switch t.Edition {
+	case "java":
+		entry, err = FetchAndCacheJavaStatus(t.Hostname, t.Port, opts, t.CacheKey)
+	case "bedrock":
+		entry, err = FetchAndCacheBedrockStatus(t.Hostname, t.Port, opts, t.CacheKey)
+	default:
+		return fmt.Errorf("unknown edition: %s", t.Edition)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	status := entry.Response(t.Query)
+
+	// Without Redis there are no other instances, so the status is sent directly to the subscribers
+	if r.Client == nil {
+		streamHub.Broadcast(t, status)
+
+		return nil
+	}
+
+	return r.Publish(t.Name, status)
+}
+
+// AcquireStreamSlot reserves a stream connection for the client and the server, and returns whether the limits allow
+// another connection. The returned functions keep the reservation alive and release it once the connection closes.
+func AcquireStreamSlot(client, edition, hostname string, port uint16) (bool, func() error, func() error, error) {
+	clientKey := fmt.Sprintf("stream-connections:client:%s", client)
+	addressKey := fmt.Sprintf("stream-connections:address:%s:%s", edition, GetCacheKey(hostname, port))
+
+	// Without Redis there are no other instances, so the connections are only counted in memory
+	if r.Client == nil {
+		acquired, release := localStreamSlots.Acquire(clientKey, addressKey)
+
+		return acquired, func() error { return nil }, release, nil
+	}
+
+	release := func() error {
+		if _, err := r.Decrement(clientKey); err != nil {
+			return err
+		}
+
+		_, err := r.Decrement(addressKey)
+
+		return err
+	}
+
+	clientCount, err := r.IncrementWithTTL(clientKey, streamSlotTTL)
+
+	if err != nil {
+		return false, nil, nil, err
+	}
+
+	addressCount, err := r.IncrementWithTTL(addressKey, streamSlotTTL)
+
+	if err != nil {
+		if _, err := r.Decrement(clientKey); err != nil {
+			slog.Error("Failed to release stream slot", "error", err, "key", clientKey)
+		}
+
+		return false, nil, nil, err
+	}
+
+	if clientCount > config.Stream.MaxPerClient || addressCount > config.Stream.MaxPerAddress {
+		return false, nil, nil, release()
+	}
+
+	keepAlive := func() error {
+		if err := r.Expire(clientKey, streamSlotTTL); err != nil {
+			return err
+		}
+
+		return r.Expire(addressKey, streamSlotTTL)
+	}
+
+	return true, keepAlive, release, nil
+}
+
+// Acquire reserves a stream connection for the client and the server if neither has reached its limit, and returns the
+// function that releases the reservation.
+func (s *StreamSlots) Acquire(clientKey, addressKey string) (bool, func() error) {
+	s.Mutex.Lock()
+
+	defer s.Mutex.Unlock()
+
+	if s.Counts[clientKey] >= config.Stream.MaxPerClient || s.Counts[addressKey] >= config.Stream.MaxPerAddress {
+		return false, nil
+	}
+
+	s.Counts[clientKey]++
+	s.Counts[addressKey]++
+
+	return true, func() error {
+		s.Mutex.Lock()
+
+		defer s.Mutex.Unlock()
+
+		for _, key := range []string{clientKey, addressKey} {
+			if s.Counts[key]--; s.Counts[key] < 1 {
+				delete(s.Counts, key)
+			}
+		}
+
+		return nil
+	}
+}
+
+// RunStream sends the initial status of the server, followed by a diff event every time the published status changes,
+// until sending an event fails or the done channel is closed. The heartbeat function is called periodically so that
+// idle connections are not closed by proxies, and so that closed connections are detected.
+func RunStream(edition, hostname string, port uint16, opts *StatusOptions, done <-chan struct{}, send func(event StreamEvent) error, heartbeat func() error, keepAlive func() error) error {
+	messages, unsubscribe := streamHub.Subscribe(edition, hostname, port, opts.Query)
+
+	defer unsubscribe()
+
+	var (
+		initial []byte
+		err     error
+	)
+
+	switch edition {
+	case "java":
+		initial, _, err = GetJavaStatus(hostname, port, opts)
+	case "bedrock":
+		initial, _, err = GetBedrockStatus(hostname, port, opts)
+	default:
+		return fmt.Errorf("unknown edition: %s", edition)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	var previous map[string]json.RawMessage
+
+	if err = json.Unmarshal(initial, &previous); err != nil {
+		return err
+	}
+
+	if err = send(StreamEvent{Type: "status", Data: initial}); err != nil {
+		return nil
+	}
+
+	ticker := time.NewTicker(config.Stream.HeartbeatInterval)
+
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return nil
+		case <-ticker.C:
+			{
+				if err = heartbeat(); err != nil {
+					return nil
+				}
+
+				if err = keepAlive(); err != nil {
+					return err
+				}
+			}
+		case message := <-messages:
+			{
+				var current map[string]json.RawMessage
+
+				if err = json.Unmarshal(message, &current); err != nil {
+					return err
+				}
+
+				diff := DiffStatus(previous, current)
+
+				previous = current
+
+				if diff == nil {
+					continue
+				}
+
+				data, err := json.Marshal(diff)
+
+				if err != nil {
+					return err
+				}
+
+				if err = send(StreamEvent{Type: "diff", Data: data}); err != nil {
+					return nil
+				}
+			}
+		}
+	}
+}
+
+// DiffStatus returns the top-level fields of the current status that differ from the previous status, with removed
+// fields set to null. Nil is returned if only the fields that change on every refresh are different.
+func DiffStatus(previous, current map[string]json.RawMessage) map[string]json.RawMessage {
+	result := make(map[string]json.RawMessage)
+	changed := false
+
+	for key, value := range current {
+		if old, ok := previous[key]; ok && bytes.Equal(old, value) {
+			continue
+		}
+
+		result[key] = value
+
+		if !Contains(streamIgnoredFields, key) {
+			changed = true
+		}
+	}
+
+	for key := range previous {
+		if _, ok := current[key]; ok {
+			continue
+		}
+
+		result[key] = json.RawMessage("null")
+
+		if !Contains(streamIgnoredFields, key) {
+			changed = true
+		}
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return result
+}
+
+// WriteServerSentEvent writes the event in the Server-Sent Events format and flushes it to the client.
+func WriteServerSentEvent(w *bufio.Writer, event StreamEvent) error {
+	if _, err := fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event.Type, event.Data); err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
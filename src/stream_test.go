@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAcquireStreamSlotWithoutRedis(t *testing.T) {
+	previous := config.Stream
+
+	config.Stream.MaxPerClient = 2
+	config.Stream.MaxPerAddress = 3
+
+	t.Cleanup(func() { config.Stream = previous })
+
+	releases := make([]func() error, 0)
+
+	acquire := func(client string, expected bool) {
+		t.Helper()
+
+		acquired, keepAlive, release, err := AcquireStreamSlot(client, "java", "play.example.com", 25565)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if acquired != expected {
+			t.Fatalf("expected acquired to be %t for client %s", expected, client)
+		}
+
+		if acquired {
+			if err = keepAlive(); err != nil {
+				t.Fatal(err)
+			}
+
+			releases = append(releases, release)
+		}
+	}
+
+	acquire("ip:a", true)
+	acquire("ip:a", true)
+	acquire("ip:a", false) // Client limit
+	acquire("ip:b", true)
+	acquire("ip:c", false) // Address limit
+
+	if err := releases[0](); err != nil {
+		t.Fatal(err)
+	}
+
+	acquire("ip:a", true)
+
+	for _, release := range releases[1:] {
+		if err := release(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if len(localStreamSlots.Counts) != 0 {
+		t.Errorf("expected every slot to be released, got %v", localStreamSlots.Counts)
+	}
+}
+
+func TestDiffStatus(t *testing.T) {
+	decode := func(value string) map[string]json.RawMessage {
+		var result map[string]json.RawMessage
+
+		if err := json.Unmarshal([]byte(value), &result); err != nil {
+			t.Fatal(err)
+		}
+
+		return result
+	}
+
+	previous := decode(`{"online":true,"players":{"online":5},"retrieved_at":1,"motd":"a"}`)
+
+	if diff := DiffStatus(previous, decode(`{"online":true,"players":{"online":5},"retrieved_at":2,"motd":"a"}`)); diff != nil {
+		t.Errorf("expected no diff when only ignored fields changed, got %v", diff)
+	}
+
+	diff := DiffStatus(previous, decode(`{"online":true,"players":{"online":6},"retrieved_at":2}`))
+
+	if string(diff["players"]) != `{"online":6}` || string(diff["retrieved_at"]) != "2" || string(diff["motd"]) != "null" {
+		t.Errorf("unexpected diff: %v", diff)
+	}
+
+	if _, ok := diff["online"]; ok {
+		t.Errorf("expected unchanged fields to be left out of the diff, got %v", diff)
+	}
+}
@@ -0,0 +1,273 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/redis/go-redis/v9"
+	"go.mongodb.org/mongo-driver/event"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer trace.Tracer = otel.Tracer("main")
+
+// StartTracing sets up the exporter configured for tracing, and returns a function that flushes the remaining spans and
+// stops the exporter. Spans are discarded if tracing is disabled.
+func StartTracing() (func(context.Context) error, error) {
+	if !config.Tracing.Enable {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	var (
+		exporter sdktrace.SpanExporter
+		err      error
+	)
+
+	switch config.Tracing.Exporter {
+	case "otlp":
+		{
+			opts := []otlptracehttp.Option{otlptracehttp.WithEndpoint(config.Tracing.Endpoint)}
+
+			if config.Tracing.Insecure {
+				opts = append(opts, otlptracehttp.WithInsecure())
+			}
+
+			exporter, err = otlptracehttp.New(context.Background(), opts...)
+		}
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter: %s", config.Tracing.Exporter)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.Tracing.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(config.Tracing.ServiceName))),
+	)
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	return provider.Shutdown, nil
+}
+
+// StartSpan starts a span as a child of any span in the context.
+func StartSpan(ctx context.Context, name string, attributes ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attributes...))
+}
+
+// EndSpan marks the span as failed if there is an error, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
+
+// StatusContext returns the context of the request that the status options belong to, or an empty context if the
+// options were not created for a request.
+func StatusContext(opts *StatusOptions) context.Context {
+	if opts.Context == nil {
+		return context.Background()
+	}
+
+	return opts.Context
+}
+
+// fiberHeaderCarrier reads and writes the trace propagation headers of a request.
+type fiberHeaderCarrier struct {
+	ctx *fiber.Ctx
+}
+
+// Get returns a copy of the header value, as the trace state and baggage parsed from it are kept by the span context
+// after the request buffer has been reused.
+func (c fiberHeaderCarrier) Get(key string) string {
+	return utils.CopyString(c.ctx.Get(key))
+}
+
+func (c fiberHeaderCarrier) Set(key, value string) {
+	c.ctx.Set(key, value)
+}
+
+func (c fiberHeaderCarrier) Keys() []string {
+	keys := make([]string, 0)
+
+	c.ctx.Request().Header.VisitAll(func(key, _ []byte) {
+		keys = append(keys, string(key))
+	})
+
+	return keys
+}
+
+// TracingMiddleware starts a span for every request, continuing the trace of the client if it sent one. The span is
+// stored in the user context of the request so that the spans started while handling it are its children.
+func TracingMiddleware(ctx *fiber.Ctx) error {
+	// Spans are exported after the request has been handled and its buffer reused, so values from the request are copied
+	method := utils.CopyString(ctx.Method())
+	parent := otel.GetTextMapPropagator().Extract(ctx.UserContext(), fiberHeaderCarrier{ctx})
+
+	spanCtx, span := tracer.Start(parent, method, trace.WithSpanKind(trace.SpanKindServer))
+
+	defer span.End()
+
+	ctx.SetUserContext(spanCtx)
+
+	err := ctx.Next()
+
+	status := ctx.Response().StatusCode()
+
+	if err != nil {
+		var fiberErr *fiber.Error
+
+		if errors.As(err, &fiberErr) {
+			status = fiberErr.Code
+		} else {
+			status = fiber.StatusInternalServerError
+		}
+
+		span.RecordError(err)
+	}
+
+	if status >= 500 {
+		span.SetStatus(codes.Error, fmt.Sprintf("status code %d", status))
+	}
+
+	span.SetName(fmt.Sprintf("%s %s", method, ctx.Route().Path))
+	span.SetAttributes(
+		semconv.HTTPRequestMethodKey.String(method),
+		semconv.HTTPRoute(ctx.Route().Path),
+		semconv.HTTPResponseStatusCode(status),
+	)
+
+	if address := ctx.Params("address"); len(address) > 0 {
+		span.SetAttributes(attribute.String("minecraft.target", utils.CopyString(address)))
+	}
+
+	if cacheHit := ctx.GetRespHeader("X-Cache-Hit"); len(cacheHit) > 0 {
+		span.SetAttributes(attribute.Bool("cache.hit", cacheHit == "true"))
+	}
+
+	return err
+}
+
+// redisTracingHook is a Redis client hook that records a span for every command.
+type redisTracingHook struct{}
+
+func (redisTracingHook) DialHook(next redis.DialHook) redis.DialHook {
+	return next
+}
+
+func (redisTracingHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		ctx, span := StartSpan(ctx, fmt.Sprintf("redis %s", cmd.Name()),
+			semconv.DBSystemRedis,
+			semconv.DBOperationName(cmd.Name()),
+		)
+
+		err := next(ctx, cmd)
+
+		if errors.Is(err, redis.Nil) {
+			EndSpan(span, nil)
+		} else {
+			EndSpan(span, err)
+		}
+
+		return err
+	}
+}
+
+func (redisTracingHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		ctx, span := StartSpan(ctx, "redis pipeline",
+			semconv.DBSystemRedis,
+			semconv.DBOperationName(strings.Join(Map(cmds, func(cmd redis.Cmder) string { return cmd.Name() }), " ")),
+		)
+
+		err := next(ctx, cmds)
+
+		if errors.Is(err, redis.Nil) {
+			EndSpan(span, nil)
+		} else {
+			EndSpan(span, err)
+		}
+
+		return err
+	}
+}
+
+// NewMongoTracingMonitor returns a MongoDB command monitor that records a span for every command.
+func NewMongoTracingMonitor() *event.CommandMonitor {
+	spans := &sync.Map{}
+
+	end := func(requestID int64, err error) {
+		if span, ok := spans.LoadAndDelete(requestID); ok {
+			EndSpan(span.(trace.Span), err)
+		}
+	}
+
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			_, span := StartSpan(ctx, fmt.Sprintf("mongodb %s", e.CommandName),
+				semconv.DBSystemMongoDB,
+				semconv.DBOperationName(e.CommandName),
+				semconv.DBNamespace(e.DatabaseName),
+			)
+
+			spans.Store(e.RequestID, span)
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			end(e.RequestID, nil)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			end(e.RequestID, errors.New(e.Failure))
+		},
+	}
+}
+
+// CombineCommandMonitors returns a MongoDB command monitor that calls each of the monitors in order.
+func CombineCommandMonitors(monitors ...*event.CommandMonitor) *event.CommandMonitor {
+	return &event.CommandMonitor{
+		Started: func(ctx context.Context, e *event.CommandStartedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Started != nil {
+					monitor.Started(ctx, e)
+				}
+			}
+		},
+		Succeeded: func(ctx context.Context, e *event.CommandSucceededEvent) {
+			for _, monitor := range monitors {
+				if monitor.Succeeded != nil {
+					monitor.Succeeded(ctx, e)
+				}
+			}
+		},
+		Failed: func(ctx context.Context, e *event.CommandFailedEvent) {
+			for _, monitor := range monitors {
+				if monitor.Failed != nil {
+					monitor.Failed(ctx, e)
+				}
+			}
+		},
+	}
+}
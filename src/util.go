@@ -1,32 +1,36 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha1"
 	_ "embed"
 	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
-	"log"
 	"math"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/mcstatus-io/mcutil/v4/util"
 	"go.mongodb.org/mongo-driver/bson"
+	"golang.org/x/net/idna"
 )
 
 var (
-	blockedServers *MutexArray[string] = nil
-	hostRegEx      *regexp.Regexp      = regexp.MustCompile(`^[A-Za-z0-9-_]+(\.[A-Za-z0-9-_]+)+(:\d{1,5})?$`)
-	ipAddressRegEx *regexp.Regexp      = regexp.MustCompile(`^\d{1,3}(\.\d{1,3}){3}$`)
+	hostRegEx       *regexp.Regexp = regexp.MustCompile(`^[A-Za-z0-9-_]+(\.[A-Za-z0-9-_]+)+$`)
+	ipAddressRegEx  *regexp.Regexp = regexp.MustCompile(`^\d{1,3}(\.\d{1,3}){3}$`)
+	hostnameProfile *idna.Profile  = idna.New(idna.MapForLookup(), idna.StrictDomainName(false))
 )
 
 // VoteOptions is the options provided as query parameters to the vote route.
@@ -48,100 +52,260 @@ type StatusOptions struct {
 	Query       bool
 	Timeout     time.Duration
 	BypassCache bool
+	MaxAge      *time.Duration
+	DisableSRV  bool
+	Trace       *ProbeTrace
+	Context     context.Context
 }
 
-// MutexArray is a thread-safe array for storing and retrieving values.
-type MutexArray[T comparable] struct {
-	List  []T
-	Mutex *sync.Mutex
+// CoalesceKey returns the key used to share a fetch of the server with other requests on this instance. Every option
+// that changes how the status is fetched or which cached status is accepted is part of the key, so requests are only
+// shared when they would have fetched the same result.
+func (o *StatusOptions) CoalesceKey(cacheKey string) string {
+	maxAge := "none"
+
+	if o.MaxAge != nil {
+		maxAge = o.MaxAge.String()
+	}
+
+	return fmt.Sprintf("%s:%t:%t:%s:%s:%t", cacheKey, o.Query, o.BypassCache, maxAge, o.Timeout, o.DisableSRV)
 }
 
-// Has checks if the given value is present in the array.
-func (m *MutexArray[T]) Has(value T) bool {
-	m.Mutex.Lock()
+// MonitorOptions is the options provided in the request body to the monitor registration route.
+type MonitorOptions struct {
+	Edition  string  `json:"edition"`
+	Address  string  `json:"address"`
+	Interval float64 `json:"interval"`
+	Query    *bool   `json:"query"`
+	Timeout  float64 `json:"timeout"`
+}
 
-	defer m.Mutex.Unlock()
+// WebhookOptions is the options provided in the request body to the webhook registration route.
+type WebhookOptions struct {
+	URL       string                  `json:"url"`
+	Format    string                  `json:"format"`
+	Addresses []WebhookAddressOptions `json:"addresses"`
+	Rules     []WebhookRule           `json:"rules"`
+	Interval  float64                 `json:"interval"`
+	Query     *bool                   `json:"query"`
+	Timeout   float64                 `json:"timeout"`
+}
 
-	for _, v := range m.List {
-		if v == value {
-			return true
-		}
-	}
+// WebhookAddressOptions is a single address watched by a webhook, as provided in the request body.
+type WebhookAddressOptions struct {
+	Edition string `json:"edition"`
+	Address string `json:"address"`
+}
 
-	return false
+// HistoryOptions is the options provided as query parameters to the history routes.
+type HistoryOptions struct {
+	From       time.Time
+	To         time.Time
+	Resolution string
+	At         *time.Time
 }
 
-// GetBlockedServerList fetches the list of blocked servers from Mojang's session server.
-func GetBlockedServerList() error {
-	resp, err := http.Get("https://sessionserver.mojang.com/blockedservers")
+// MemoryCache is a set of values kept in memory for a fixed duration, used to avoid retrieving the same values from
+// MongoDB on every request. Expired entries are removed when they are found, and every entry is checked at most once
+// per duration so that keys which are never requested again do not stay in memory.
+type MemoryCache[T any] struct {
+	Duration time.Duration
+	entries  map[string]memoryCacheEntry[T]
+	sweptAt  time.Time
+	mutex    sync.Mutex
+}
 
-	if err != nil {
-		return err
+type memoryCacheEntry[T any] struct {
+	Value     T
+	ExpiresAt time.Time
+}
+
+// NewMemoryCache returns an empty cache that keeps values for the duration.
+func NewMemoryCache[T any](duration time.Duration) *MemoryCache[T] {
+	return &MemoryCache[T]{
+		Duration: duration,
+		entries:  make(map[string]memoryCacheEntry[T]),
+		sweptAt:  time.Now(),
 	}
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("mojang: unexpected status code: %d", resp.StatusCode)
+// Get returns the value of the key, calling the load function and keeping its result if the value is not in the cache
+// or has expired.
+func (c *MemoryCache[T]) Get(key string, load func() (T, error)) (T, error) {
+	c.mutex.Lock()
+
+	entry, ok := c.entries[key]
+
+	if ok && !time.Now().Before(entry.ExpiresAt) {
+		delete(c.entries, key)
+
+		ok = false
 	}
 
-	defer resp.Body.Close()
+	c.mutex.Unlock()
 
-	body, err := io.ReadAll(resp.Body)
+	if ok {
+		return entry.Value, nil
+	}
+
+	value, err := load()
 
 	if err != nil {
-		return err
+		return value, err
+	}
+
+	c.mutex.Lock()
+
+	defer c.mutex.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(c.sweptAt) >= c.Duration {
+		for k, v := range c.entries {
+			if !now.Before(v.ExpiresAt) {
+				delete(c.entries, k)
+			}
+		}
+
+		c.sweptAt = now
+	}
+
+	c.entries[key] = memoryCacheEntry[T]{
+		Value:     value,
+		ExpiresAt: now.Add(c.Duration),
+	}
+
+	return value, nil
+}
+
+// Len returns the number of entries in the cache, including any that have expired but not been removed yet.
+func (c *MemoryCache[T]) Len() int {
+	c.mutex.Lock()
+
+	defer c.mutex.Unlock()
+
+	return len(c.entries)
+}
+
+// HashSet is a set of values that cannot be modified after it is created, so it is safe to read from concurrently
+// without locking.
+type HashSet[T comparable] struct {
+	values map[T]struct{}
+}
+
+// NewHashSet returns a set containing the given values.
+func NewHashSet[T comparable](values []T) *HashSet[T] {
+	result := &HashSet[T]{
+		values: make(map[T]struct{}, len(values)),
 	}
 
-	blockedServers = &MutexArray[string]{
-		List:  strings.Split(string(body), "\n"),
-		Mutex: &sync.Mutex{},
+	for _, value := range values {
+		result.values[value] = struct{}{}
 	}
 
-	return nil
+	return result
+}
+
+// Has checks if the given value is present in the set.
+func (s *HashSet[T]) Has(value T) bool {
+	_, ok := s.values[value]
+
+	return ok
 }
 
-// IsBlockedAddress checks if the given address is in the blocked servers list.
-func IsBlockedAddress(address string) bool {
+// Values returns every value in the set, in no particular order.
+func (s *HashSet[T]) Values() []T {
+	result := make([]T, 0, len(s.values))
+
+	for value := range s.values {
+		result = append(result, value)
+	}
+
+	return result
+}
+
+// Len returns the number of values in the set.
+func (s *HashSet[T]) Len() int {
+	return len(s.values)
+}
+
+// GetBlocklistPatterns returns every pattern that the address is checked against in the blocked servers list, starting
+// with the exact address, followed by wildcard domains for hostnames or wildcard ranges for IPv4 addresses.
+func GetBlocklistPatterns(address string) []string {
 	addressSegments := strings.Split(strings.ToLower(address), ".")
 	isIPv4Address := ipAddressRegEx.MatchString(address)
 
-	for i := range addressSegments {
-		var checkAddress string
+	result := make([]string, 0, len(addressSegments))
 
+	for i := range addressSegments {
 		if i == 0 {
-			checkAddress = strings.Join(addressSegments, ".")
+			result = append(result, strings.Join(addressSegments, "."))
 		} else if isIPv4Address {
-			checkAddress = fmt.Sprintf("%s.*", strings.Join(addressSegments[0:len(addressSegments)-i], "."))
+			result = append(result, fmt.Sprintf("%s.*", strings.Join(addressSegments[0:len(addressSegments)-i], ".")))
 		} else {
-			checkAddress = fmt.Sprintf("*.%s", strings.Join(addressSegments[i:], "."))
+			result = append(result, fmt.Sprintf("*.%s", strings.Join(addressSegments[i:], ".")))
 		}
+	}
 
-		if blockedServers.Has(SHA256(checkAddress)) {
-			return true
+	return result
+}
+
+// ResolveIPAddresses resolves every IP address of the hostname, and returns them along with the address that is used to
+// connect to the server, which is the first IPv4 address if there is one.
+func ResolveIPAddresses(hostname string) (*string, []string, error) {
+	ips, err := net.LookupIP(hostname)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(ips) < 1 {
+		return nil, nil, fmt.Errorf("no IP addresses found for %s", hostname)
+	}
+
+	var (
+		preferred *string  = nil
+		result    []string = make([]string, 0, len(ips))
+	)
+
+	for _, ip := range ips {
+		if preferred == nil && ip.To4() != nil {
+			preferred = PointerOf(ip.String())
 		}
+
+		result = append(result, ip.String())
 	}
 
-	return false
+	if preferred == nil {
+		preferred = PointerOf(result[0])
+	}
+
+	return preferred, result, nil
 }
 
 // ParseAddress extracts the hostname and port from the given address string, and returns the default port if none is provided.
 func ParseAddress(address string, defaultPort uint16) (string, uint16, error) {
-	if !hostRegEx.MatchString(address) {
-		return "", 0, fmt.Errorf("'%s' does not match any known address", address)
+	if value, err := url.PathUnescape(address); err == nil {
+		address = value
 	}
 
-	splitHost := strings.SplitN(address, ":", 2)
+	host, portValue, hasPort := strings.Cut(address, ":")
+
+	host, err := NormalizeHostname(host)
 
-	if len(splitHost) < 1 {
+	if err != nil || !hostRegEx.MatchString(host) {
 		return "", 0, fmt.Errorf("'%s' does not match any known address", address)
 	}
 
-	host := splitHost[0]
+	// The address usually comes from the route parameters, which point into the request buffer that is reused once the
+	// handler returns, so the host is copied for use in background refreshes
+	host = strings.Clone(host)
 
-	if len(splitHost) < 2 {
+	if !hasPort {
 		return host, defaultPort, nil
 	}
 
-	port, err := strconv.ParseUint(splitHost[1], 10, 16)
+	port, err := strconv.ParseUint(portValue, 10, 16)
 
 	if err != nil {
 		return "", 0, err
@@ -150,6 +314,38 @@ func ParseAddress(address string, defaultPort uint16) (string, uint16, error) {
 	return host, uint16(port), nil
 }
 
+// NormalizeHostname converts the hostname into its lowercase ASCII form without a trailing dot, so that equivalent
+// hostnames are treated as the same server.
+func NormalizeHostname(hostname string) (string, error) {
+	result, err := hostnameProfile.ToASCII(strings.TrimSuffix(hostname, "."))
+
+	if err != nil {
+		return "", err
+	}
+
+	return strings.ToLower(result), nil
+}
+
+// ParseEditionAddress validates the edition and extracts the hostname and port from the address, using the default port of the edition.
+func ParseEditionAddress(edition, address string) (string, string, uint16, error) {
+	switch edition {
+	case "java":
+		{
+			hostname, port, err := ParseAddress(strings.ToLower(address), util.DefaultJavaPort)
+
+			return "java", hostname, port, err
+		}
+	case "bedrock":
+		{
+			hostname, port, err := ParseAddress(strings.ToLower(address), util.DefaultBedrockPort)
+
+			return "bedrock", hostname, port, err
+		}
+	default:
+		return "", "", 0, fmt.Errorf("unknown edition: %s", edition)
+	}
+}
+
 // GetVoteOptions parses the vote options from the provided query parameters.
 func GetVoteOptions(ctx *fiber.Ctx) (*VoteOptions, error) {
 	result := VoteOptions{}
@@ -240,7 +436,9 @@ func GetVoteOptions(ctx *fiber.Ctx) (*VoteOptions, error) {
 
 // GetStatusOptions returns the options for status routes, with the default values filled in.
 func GetStatusOptions(ctx *fiber.Ctx) (*StatusOptions, error) {
-	result := &StatusOptions{}
+	result := &StatusOptions{
+		Context: ctx.UserContext(),
+	}
 
 	// Query
 	{
@@ -257,16 +455,252 @@ func GetStatusOptions(ctx *fiber.Ctx) (*StatusOptions, error) {
 		result.BypassCache = Contains(config.Cache.BypassTokens, ctx.Get("Authorization"))
 	}
 
+	// Max Age
+	if value := ctx.Query("maxAge"); len(value) > 0 {
+		seconds, err := strconv.ParseFloat(value, 64)
+
+		if err != nil || seconds < 0 {
+			return nil, fmt.Errorf("invalid 'maxAge' query parameter: %s", value)
+		}
+
+		minimumMaxAge, ok := config.Cache.TokenMinimumMaxAges[ctx.Get("Authorization")]
+
+		if !ok {
+			minimumMaxAge = config.Cache.MinimumMaxAge
+		}
+
+		result.MaxAge = PointerOf(max(time.Duration(float64(time.Second)*seconds), minimumMaxAge))
+	}
+
+	return result, nil
+}
+
+// GetMonitorOptions parses the monitor options from the request body, with the default values filled in.
+func GetMonitorOptions(ctx *fiber.Ctx) (*MonitorOptions, error) {
+	result := &MonitorOptions{}
+
+	if err := ctx.BodyParser(result); err != nil {
+		return nil, fmt.Errorf("invalid request body: %v", err)
+	}
+
+	// Edition
+	{
+		if result.Edition != "java" && result.Edition != "bedrock" {
+			return nil, fmt.Errorf("invalid 'edition' value: %s", result.Edition)
+		}
+	}
+
+	// Address
+	{
+		if len(result.Address) < 1 {
+			return nil, errors.New("missing 'address' value")
+		}
+	}
+
+	// Interval
+	{
+		if time.Duration(result.Interval*float64(time.Second)) < config.Monitor.MinimumInterval {
+			return nil, fmt.Errorf("'interval' value must be at least %d seconds", int(config.Monitor.MinimumInterval.Seconds()))
+		}
+	}
+
+	// Query
+	{
+		if result.Query == nil {
+			result.Query = PointerOf(true)
+		}
+	}
+
+	// Timeout
+	{
+		if result.Timeout == 0 {
+			result.Timeout = 5.0
+		}
+
+		result.Timeout = math.Min(math.Max(result.Timeout, 0.5), result.Interval)
+	}
+
 	return result, nil
 }
 
+// GetWebhookOptions parses the webhook options from the request body, with the default values filled in and the addresses
+// parsed into their host and port.
+func GetWebhookOptions(ctx *fiber.Ctx) (*WebhookOptions, []WebhookAddress, error) {
+	result := &WebhookOptions{}
+
+	if err := ctx.BodyParser(result); err != nil {
+		return nil, nil, fmt.Errorf("invalid request body: %v", err)
+	}
+
+	// URL
+	{
+		parsedURL, err := url.Parse(result.URL)
+
+		if err != nil || (parsedURL.Scheme != "http" && parsedURL.Scheme != "https") || len(parsedURL.Host) < 1 {
+			return nil, nil, fmt.Errorf("invalid 'url' value: %s", result.URL)
+		}
+	}
+
+	// Format
+	{
+		if len(result.Format) < 1 {
+			result.Format = WebhookFormatJSON
+		}
+
+		if !Contains(webhookFormats, result.Format) {
+			return nil, nil, fmt.Errorf("invalid 'format' value: %s", result.Format)
+		}
+	}
+
+	// Addresses
+	addresses := make([]WebhookAddress, 0, len(result.Addresses))
+
+	{
+		// Webhooks that only subscribe to blocklist changes do not need to watch any addresses
+		if len(result.Addresses) < 1 && !All(result.Rules, func(rule WebhookRule) bool { return Contains(blocklistRuleTypes, rule.Type) }) {
+			return nil, nil, errors.New("missing 'addresses' value")
+		}
+
+		if len(result.Addresses) > config.Webhooks.MaxAddresses {
+			return nil, nil, fmt.Errorf("'addresses' value cannot contain more than %d addresses", config.Webhooks.MaxAddresses)
+		}
+
+		for _, address := range result.Addresses {
+			edition, hostname, port, err := ParseEditionAddress(address.Edition, address.Address)
+
+			if err != nil {
+				return nil, nil, fmt.Errorf("invalid 'addresses' value: %s", address.Address)
+			}
+
+			addresses = append(addresses, WebhookAddress{
+				Edition: edition,
+				Host:    hostname,
+				Port:    port,
+			})
+		}
+	}
+
+	// Rules
+	{
+		if len(result.Rules) < 1 {
+			return nil, nil, errors.New("missing 'rules' value")
+		}
+
+		for _, rule := range result.Rules {
+			if !Contains(webhookRuleTypes, rule.Type) {
+				return nil, nil, fmt.Errorf("invalid 'rules' value: %s", rule.Type)
+			}
+
+			if (rule.Type == WebhookRulePlayersAbove || rule.Type == WebhookRulePlayersBelow) && rule.Threshold == nil {
+				return nil, nil, fmt.Errorf("missing 'threshold' value for rule: %s", rule.Type)
+			}
+		}
+	}
+
+	// Interval
+	{
+		if time.Duration(result.Interval*float64(time.Second)) < config.Webhooks.MinimumInterval {
+			return nil, nil, fmt.Errorf("'interval' value must be at least %d seconds", int(config.Webhooks.MinimumInterval.Seconds()))
+		}
+	}
+
+	// Query
+	{
+		if result.Query == nil {
+			result.Query = PointerOf(false)
+		}
+	}
+
+	// Timeout
+	{
+		if result.Timeout == 0 {
+			result.Timeout = 5.0
+		}
+
+		result.Timeout = math.Min(math.Max(result.Timeout, 0.5), result.Interval)
+	}
+
+	return result, addresses, nil
+}
+
+// GetHistoryOptions returns the options for history routes, with the default values filled in. The default range is
+// the provided duration before the end of the range.
+func GetHistoryOptions(ctx *fiber.Ctx, defaultRange time.Duration) (*HistoryOptions, error) {
+	result := &HistoryOptions{}
+
+	// To
+	{
+		value, err := ParseTimeQuery(ctx, "to", time.Now().UTC())
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.To = value
+	}
+
+	// From
+	{
+		value, err := ParseTimeQuery(ctx, "from", result.To.Add(-defaultRange))
+
+		if err != nil {
+			return nil, err
+		}
+
+		if !value.Before(result.To) {
+			return nil, errors.New("query parameter 'from' must be before 'to'")
+		}
+
+		result.From = value
+	}
+
+	// Resolution
+	{
+		result.Resolution = ctx.Query("resolution", "raw")
+
+		if _, ok := historyResolutions[result.Resolution]; !ok && result.Resolution != "raw" {
+			return nil, fmt.Errorf("invalid 'resolution' query parameter: %s", result.Resolution)
+		}
+	}
+
+	// At
+	if len(ctx.Query("at")) > 0 {
+		value, err := ParseTimeQuery(ctx, "at", time.Time{})
+
+		if err != nil {
+			return nil, err
+		}
+
+		result.At = &value
+	}
+
+	return result, nil
+}
+
+// ParseTimeQuery parses the query parameter as an RFC 3339 timestamp, or returns the default value if it is empty.
+func ParseTimeQuery(ctx *fiber.Ctx, key string, defaultValue time.Time) (time.Time, error) {
+	value := ctx.Query(key)
+
+	if len(value) < 1 {
+		return defaultValue, nil
+	}
+
+	parsedTime, err := time.Parse(time.RFC3339, value)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid '%s' query parameter: %s", key, value)
+	}
+
+	return parsedTime.UTC(), nil
+}
+
 // GetInstanceID returns the INSTANCE_ID environment variable parsed as an unsigned 16-bit integer.
 func GetInstanceID() (uint16, error) {
 	if instanceID := os.Getenv("INSTANCE_ID"); len(instanceID) > 0 {
 		value, err := strconv.ParseUint(instanceID, 10, 16)
 
 		if err != nil {
-			log.Fatal(err)
+			Fatal("Invalid INSTANCE_ID environment variable", "error", err)
 		}
 
 		return uint16(value), nil
@@ -275,16 +709,13 @@ func GetInstanceID() (uint16, error) {
 	return 0, nil
 }
 
-// GetCacheKey generates a unique key used for caching status results in Redis.
-func GetCacheKey(hostname string, port uint16, opts *StatusOptions) string {
+// GetCacheKey generates a unique key used for caching status results in Redis. The port is always included so that
+// addresses with and without the default port share the same key.
+func GetCacheKey(hostname string, port uint16) string {
 	values := &url.Values{}
 	values.Set("hostname", hostname)
 	values.Set("port", strconv.FormatUint(uint64(port), 10))
 
-	if opts != nil {
-		values.Set("query", strconv.FormatBool(opts.Query))
-	}
-
 	return SHA256(values.Encode())
 }
 
@@ -294,9 +725,9 @@ func Authenticate(ctx *fiber.Ctx) (bool, error) {
 		return true, nil
 	}
 
-	authToken := ctx.Get("Authorization")
+	database := db.WithContext(ctx.UserContext())
 
-	if len(authToken) < 1 {
+	if len(ctx.Get("Authorization")) < 1 {
 		if err := ctx.Status(http.StatusUnauthorized).SendString("Missing 'Authorization' header in request"); err != nil {
 			return false, err
 		}
@@ -304,7 +735,7 @@ func Authenticate(ctx *fiber.Ctx) (bool, error) {
 		return false, nil
 	}
 
-	token, err := db.GetTokenByToken(authToken)
+	token, err := LookupRequestToken(ctx)
 
 	if err != nil {
 		return false, err
@@ -318,11 +749,17 @@ func Authenticate(ctx *fiber.Ctx) (bool, error) {
 		return false, nil
 	}
 
-	if err = db.IncrementApplicationRequestCount(token.Application); err != nil {
+	ctx.Locals("token", token)
+
+	if withinQuota, err := CheckQuota(ctx, token); err != nil || !withinQuota {
 		return false, err
 	}
 
-	if err = db.UpdateToken(
+	if err = database.IncrementApplicationRequestCount(token.Application); err != nil {
+		return false, err
+	}
+
+	if err = database.UpdateToken(
 		token.ID,
 		bson.M{
 			"$inc": bson.M{"requestCount": 1},
@@ -332,7 +769,7 @@ func Authenticate(ctx *fiber.Ctx) (bool, error) {
 		return false, err
 	}
 
-	if err = db.UpsertRequestLog(
+	if err = database.UpsertRequestLog(
 		bson.M{
 			"application": token.Application,
 			"timestamp":   GetStartOfHour(),
@@ -353,6 +790,86 @@ func Authenticate(ctx *fiber.Ctx) (bool, error) {
 	return true, nil
 }
 
+// LookupRequestToken returns the token in the Authorization header of the request, or nil if there is no valid token. The
+// result is kept for the rest of the request so that the token is only retrieved from MongoDB once.
+func LookupRequestToken(ctx *fiber.Ctx) (*Token, error) {
+	if token, ok := ctx.Locals("token-lookup").(*Token); ok {
+		return token, nil
+	}
+
+	authToken := ctx.Get("Authorization")
+
+	if config.MongoDB == nil || len(authToken) < 1 {
+		return nil, nil
+	}
+
+	token, err := db.WithContext(ctx.UserContext()).GetTokenByToken(authToken)
+
+	if err != nil {
+		return nil, err
+	}
+
+	ctx.Locals("token-lookup", token)
+
+	return token, nil
+}
+
+// GetRequestToken returns the token that authenticated the current request, or nil if there is none.
+func GetRequestToken(ctx *fiber.Ctx) *Token {
+	token, ok := ctx.Locals("token").(*Token)
+
+	if !ok {
+		return nil
+	}
+
+	return token
+}
+
+// AuthenticateApplication requires authentication for the current request, and returns the token used if the request
+// was authenticated. Routes that belong to an application cannot be used without MongoDB.
+func AuthenticateApplication(ctx *fiber.Ctx) (*Token, error) {
+	if config.MongoDB == nil {
+		return nil, ctx.Status(http.StatusNotImplemented).SendString("This route requires MongoDB to be configured")
+	}
+
+	authorized, err := Authenticate(ctx)
+
+	if err != nil || !authorized {
+		return nil, err
+	}
+
+	return GetRequestToken(ctx), nil
+}
+
+// AuthenticateAdmin requires the current request to be authorized using one of the admin tokens.
+func AuthenticateAdmin(ctx *fiber.Ctx) (bool, error) {
+	authToken := ctx.Get("Authorization")
+
+	if len(authToken) < 1 || !Contains(config.AdminTokens, authToken) {
+		if err := ctx.Status(http.StatusUnauthorized).SendString("Missing or invalid admin token in 'Authorization' header"); err != nil {
+			return false, err
+		}
+
+		return false, nil
+	}
+
+	return true, nil
+}
+
+// AuthenticateMetrics checks access to the Prometheus routes, which are only available if metrics are enabled, and
+// require one of the metrics tokens if any are configured.
+func AuthenticateMetrics(ctx *fiber.Ctx) (bool, error) {
+	if !config.Metrics.Enable {
+		return false, ctx.SendStatus(http.StatusNotFound)
+	}
+
+	if len(config.Metrics.Tokens) > 0 && !Contains(config.Metrics.Tokens, strings.TrimPrefix(ctx.Get("Authorization"), "Bearer ")) {
+		return false, ctx.Status(http.StatusUnauthorized).SendString("Invalid or missing metrics token")
+	}
+
+	return true, nil
+}
+
 // SHA256 returns the result of hashing the input value using SHA256 algorithm.
 func SHA256(input string) string {
 	result := sha1.Sum([]byte(input))
@@ -360,6 +877,52 @@ func SHA256(input string) string {
 	return hex.EncodeToString(result[:])
 }
 
+// AppendJSONFields adds the fields to the end of the JSON encoded object, without decoding the existing object.
+func AppendJSONFields[T any](data []byte, fields map[string]T) []byte {
+	if len(fields) < 1 || len(data) < 2 || data[len(data)-1] != '}' {
+		return data
+	}
+
+	result := make([]byte, 0, len(data)+len(fields)*32)
+	result = append(result, data[:len(data)-1]...)
+
+	keys := make([]string, 0, len(fields))
+
+	for key := range fields {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+
+	needsComma := len(data) > 2
+
+	for _, key := range keys {
+		encodedKey, err := json.Marshal(key)
+
+		if err != nil {
+			continue
+		}
+
+		encodedValue, err := json.Marshal(fields[key])
+
+		if err != nil {
+			continue
+		}
+
+		if needsComma {
+			result = append(result, ',')
+		}
+
+		result = append(result, encodedKey...)
+		result = append(result, ':')
+		result = append(result, encodedValue...)
+
+		needsComma = true
+	}
+
+	return append(result, '}')
+}
+
 // PointerOf returns a pointer of the argument passed.
 func PointerOf[T any](v T) *T {
 	return &v
@@ -376,6 +939,17 @@ func Contains[T comparable](arr []T, v T) bool {
 	return false
 }
 
+// All returns true if the provided function returns true for every value in the array.
+func All[T any](arr []T, f func(T) bool) bool {
+	for _, v := range arr {
+		if !f(v) {
+			return false
+		}
+	}
+
+	return true
+}
+
 // Map applies the provided map function to all of the values in the array and returns the result.
 func Map[I, O any](arr []I, f func(I) O) []O {
 	result := make([]O, len(arr))
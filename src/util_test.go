@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// benchmarkBlocklistSize is roughly the size of the EULA blocked servers list.
+const benchmarkBlocklistSize = 2500
+
+// mutexArray is the mutex guarded slice that the blocked servers list was stored in before HashSet, kept here so that
+// the benchmarks can compare against it.
+type mutexArray[T comparable] struct {
+	List  []T
+	Mutex *sync.Mutex
+}
+
+func (m *mutexArray[T]) Has(value T) bool {
+	m.Mutex.Lock()
+
+	defer m.Mutex.Unlock()
+
+	for _, v := range m.List {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// newBenchmarkBlocklist returns the hashes of a blocked servers list, and the hashes looked up by the benchmarks. Most
+// lookups are of addresses that are not blocked, as every address is checked against several patterns.
+func newBenchmarkBlocklist() ([]string, []string) {
+	hashes := make([]string, 0, benchmarkBlocklistSize)
+
+	for i := 0; i < benchmarkBlocklistSize; i++ {
+		hashes = append(hashes, SHA256(fmt.Sprintf("blocked-%d.example.com", i)))
+	}
+
+	lookups := make([]string, 0, 64)
+
+	for i := 0; i < 64; i++ {
+		if i%8 == 0 {
+			lookups = append(lookups, hashes[i*37%len(hashes)])
+		} else {
+			lookups = append(lookups, SHA256(fmt.Sprintf("allowed-%d.example.com", i)))
+		}
+	}
+
+	return hashes, lookups
+}
+
+func BenchmarkMutexArrayHasParallel(b *testing.B) {
+	hashes, lookups := newBenchmarkBlocklist()
+	list := &mutexArray[string]{List: hashes, Mutex: &sync.Mutex{}}
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			list.Has(lookups[i%len(lookups)])
+		}
+	})
+}
+
+func BenchmarkHashSetHasParallel(b *testing.B) {
+	hashes, lookups := newBenchmarkBlocklist()
+	set := NewHashSet(hashes)
+
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		for i := 0; pb.Next(); i++ {
+			set.Has(lookups[i%len(lookups)])
+		}
+	})
+}
+
+func TestHashSet(t *testing.T) {
+	values := []string{"a", "b", "c", "b"}
+	set := NewHashSet(values)
+
+	values[0] = "z"
+
+	if !set.Has("a") || !set.Has("b") || set.Has("z") {
+		t.Error("expected the set to contain the values it was created with")
+	}
+
+	if set.Len() != 3 {
+		t.Errorf("expected duplicate values to be counted once, got %d", set.Len())
+	}
+}
+
+func TestMemoryCache(t *testing.T) {
+	cache := NewMemoryCache[int](time.Millisecond * 20)
+	loads := 0
+
+	load := func(value int) func() (int, error) {
+		return func() (int, error) {
+			loads++
+
+			return value, nil
+		}
+	}
+
+	if value, _ := cache.Get("a", load(1)); value != 1 {
+		t.Errorf("expected the loaded value, got %d", value)
+	}
+
+	if value, _ := cache.Get("a", load(2)); value != 1 || loads != 1 {
+		t.Errorf("expected the cached value without loading again, got %d after %d loads", value, loads)
+	}
+
+	if _, err := cache.Get("b", func() (int, error) { return 0, errors.New("failed") }); err == nil {
+		t.Error("expected the error of the load function")
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	if value, _ := cache.Get("a", load(3)); value != 3 || loads != 2 {
+		t.Errorf("expected the expired value to be loaded again, got %d after %d loads", value, loads)
+	}
+
+	time.Sleep(time.Millisecond * 30)
+
+	// Storing another key sweeps the entries that expired and were never requested again
+	cache.Get("c", load(4))
+
+	if cache.Len() != 1 {
+		t.Errorf("expected expired entries to be removed, got %d entries", cache.Len())
+	}
+}
@@ -0,0 +1,473 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+const (
+	WebhookRuleOffline          = "offline"
+	WebhookRuleOnline           = "online"
+	WebhookRuleVersionChanged   = "version_changed"
+	WebhookRulePlayersAbove     = "players_above"
+	WebhookRulePlayersBelow     = "players_below"
+	WebhookRuleBlocklistAdded   = "blocklist_added"
+	WebhookRuleBlocklistRemoved = "blocklist_removed"
+
+	WebhookFormatJSON    = "json"
+	WebhookFormatDiscord = "discord"
+	WebhookFormatSlack   = "slack"
+)
+
+var (
+	webhookRuleTypes   []string     = []string{WebhookRuleOffline, WebhookRuleOnline, WebhookRuleVersionChanged, WebhookRulePlayersAbove, WebhookRulePlayersBelow, WebhookRuleBlocklistAdded, WebhookRuleBlocklistRemoved}
+	blocklistRuleTypes []string     = []string{WebhookRuleBlocklistAdded, WebhookRuleBlocklistRemoved}
+	webhookFormats     []string     = []string{WebhookFormatJSON, WebhookFormatDiscord, WebhookFormatSlack}
+	sharedAddressSpace netip.Prefix = netip.MustParsePrefix("100.64.0.0/10")
+	// webhookClient is shared by every delivery so that connections to receivers are reused, and idle connections are
+	// closed after a while. The timeout of a delivery is set on its request, as the config is read after this is created.
+	webhookClient *http.Client = &http.Client{
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{
+				Control: CheckWebhookDialAddress,
+			}).DialContext,
+			MaxIdleConnsPerHost: 4,
+			IdleConnTimeout:     time.Second * 90,
+		},
+	}
+	webhookEventColors map[string]int = map[string]int{
+		WebhookRuleOffline:          0xE74C3C,
+		WebhookRuleOnline:           0x2ECC71,
+		WebhookRuleBlocklistAdded:   0xE74C3C,
+		WebhookRuleBlocklistRemoved: 0x2ECC71,
+	}
+)
+
+// WebhookEvent is a change in the status of a watched server that matched one of the rules of a webhook, or a change to
+// the EULA blocked servers list for the blocklist rules.
+type WebhookEvent struct {
+	Type            string           `json:"type"`
+	Rule            WebhookRule      `json:"rule"`
+	Address         WebhookAddress   `json:"address"`
+	Previous        *StatusSample    `json:"previous"`
+	Current         *StatusSample    `json:"current"`
+	BlocklistChange *BlocklistChange `json:"blocklist_change,omitempty"`
+	Timestamp       time.Time        `json:"timestamp"`
+}
+
+// StartWebhooks starts polling the addresses watched by webhooks in the background, using the same scheduling as monitors.
+func StartWebhooks() {
+	StartScheduler(
+		"webhook",
+		config.Webhooks.ScanInterval,
+		config.Webhooks.Concurrency,
+		func(limit int64) ([]Webhook, error) {
+			return db.GetDueWebhooks(time.Now().UTC(), limit)
+		},
+		func(webhook Webhook) string {
+			return webhook.ID
+		},
+		PollWebhook,
+	)
+}
+
+// PollWebhook fetches a fresh status of every address watched by the webhook, compares it to the state from the previous
+// poll and delivers an event for each rule that matched, unless another instance is already polling the same webhook or
+// has polled it since it was found to be due.
+func PollWebhook(webhook Webhook) error {
+	mutex := r.NewMutex(fmt.Sprintf("webhook-lock:%s", webhook.ID))
+
+	acquired, err := mutex.TryLock()
+
+	if err != nil || !acquired {
+		return err
+	}
+
+	defer mutex.KeepAlive()()
+
+	now := time.Now().UTC()
+
+	// Another instance may have polled the webhook between the scan and acquiring the lock, so the next poll is only
+	// scheduled if the webhook is still due
+	claimed, err := db.ClaimWebhook(webhook.ID, now, bson.M{
+		"$set": bson.M{
+			"nextPollAt": GetNextPollTime(now, time.Duration(webhook.Interval)*time.Second),
+		},
+	})
+
+	if err != nil || !claimed {
+		return err
+	}
+
+	for _, address := range webhook.Addresses {
+		sample, err := FetchStatusSample(address.Edition, address.Host, address.Port, &StatusOptions{
+			Query:   webhook.Query,
+			Timeout: time.Duration(webhook.Timeout * float64(time.Second)),
+		})
+
+		if err != nil {
+			return err
+		}
+
+		stateID := fmt.Sprintf("%s:%s:%s:%d", webhook.ID, address.Edition, address.Host, address.Port)
+
+		state, err := db.GetWebhookState(stateID)
+
+		if err != nil {
+			return err
+		}
+
+		if err = db.UpsertWebhookState(WebhookState{
+			ID:        stateID,
+			Webhook:   webhook.ID,
+			Sample:    *sample,
+			UpdatedAt: now,
+		}); err != nil {
+			return err
+		}
+
+		// The first poll of an address only records the state to compare against
+		if state == nil {
+			continue
+		}
+
+		for _, event := range EvaluateWebhookRules(webhook.Rules, address, &state.Sample, sample) {
+			go func(event WebhookEvent) {
+				if _, err := DeliverWebhook(webhook, event, config.Webhooks.MaxAttempts); err != nil {
+					slog.Error("Failed to record webhook delivery", "error", err, "id", webhook.ID)
+				}
+			}(event)
+		}
+	}
+
+	return nil
+}
+
+// EvaluateWebhookRules returns an event for each of the rules that matched the change from the previous to the current sample.
+func EvaluateWebhookRules(rules []WebhookRule, address WebhookAddress, previous, current *StatusSample) []WebhookEvent {
+	result := make([]WebhookEvent, 0)
+
+	for _, rule := range rules {
+		var matched bool
+
+		switch rule.Type {
+		case WebhookRuleOffline:
+			matched = previous.Online && !current.Online
+		case WebhookRuleOnline:
+			matched = !previous.Online && current.Online
+		case WebhookRuleVersionChanged:
+			matched = previous.Online && current.Online && previous.Version != nil && current.Version != nil && *previous.Version != *current.Version
+		case WebhookRulePlayersAbove:
+			matched = rule.Threshold != nil && previous.PlayersOnline != nil && current.PlayersOnline != nil && *previous.PlayersOnline <= *rule.Threshold && *current.PlayersOnline > *rule.Threshold
+		case WebhookRulePlayersBelow:
+			matched = rule.Threshold != nil && previous.PlayersOnline != nil && current.PlayersOnline != nil && *previous.PlayersOnline >= *rule.Threshold && *current.PlayersOnline < *rule.Threshold
+		}
+
+		if !matched {
+			continue
+		}
+
+		result = append(result, WebhookEvent{
+			Type:      rule.Type,
+			Rule:      rule,
+			Address:   address,
+			Previous:  previous,
+			Current:   current,
+			Timestamp: current.Timestamp,
+		})
+	}
+
+	return result
+}
+
+// DeliverBlocklistChanges delivers an event for each of the changes to the EULA blocked servers list to every webhook with
+// a rule matching the type of change.
+func DeliverBlocklistChanges(changes []BlocklistChange) {
+	webhooks, err := db.GetWebhooksByRuleTypes(blocklistRuleTypes)
+
+	if err != nil {
+		slog.Error("Failed to retrieve blocklist webhooks", "error", err)
+
+		return
+	}
+
+	for _, webhook := range webhooks {
+		go func(webhook Webhook) {
+			for _, change := range changes {
+				ruleType := WebhookRuleBlocklistAdded
+
+				if change.Type == BlocklistChangeRemoved {
+					ruleType = WebhookRuleBlocklistRemoved
+				}
+
+				for _, rule := range webhook.Rules {
+					if rule.Type != ruleType {
+						continue
+					}
+
+					if _, err := DeliverWebhook(webhook, WebhookEvent{
+						Type:            ruleType,
+						Rule:            rule,
+						BlocklistChange: PointerOf(change),
+						Timestamp:       change.Timestamp,
+					}, config.Webhooks.MaxAttempts); err != nil {
+						slog.Error("Failed to record webhook delivery", "error", err, "id", webhook.ID)
+					}
+				}
+			}
+		}(webhook)
+	}
+}
+
+// DeliverWebhook sends the event to the URL of the webhook, retrying with an exponential backoff until it succeeds or the
+// maximum attempts are reached, and records the outcome of the delivery.
+func DeliverWebhook(webhook Webhook, event WebhookEvent, maxAttempts int) (*WebhookDelivery, error) {
+	delivery := &WebhookDelivery{
+		ID:          RandomHexString(16),
+		Webhook:     webhook.ID,
+		Application: webhook.Application,
+		Event:       event.Type,
+		Address:     event.Address,
+		Attempts:    0,
+		StatusCode:  nil,
+		Success:     false,
+		Error:       nil,
+		CreatedAt:   time.Now().UTC(),
+	}
+
+	payload, err := BuildWebhookPayload(webhook, event)
+
+	if err != nil {
+		return nil, err
+	}
+
+	delivery.Payload = string(payload)
+
+	backoff := config.Webhooks.RetryBackoff
+
+	for delivery.Attempts < max(maxAttempts, 1) {
+		if delivery.Attempts > 0 {
+			time.Sleep(backoff)
+
+			backoff *= 2
+		}
+
+		delivery.Attempts++
+
+		statusCode, err := SendWebhookRequest(webhook, event, payload)
+
+		if statusCode != 0 {
+			delivery.StatusCode = PointerOf(statusCode)
+		}
+
+		if err != nil {
+			delivery.Error = PointerOf(err.Error())
+		} else {
+			delivery.Error = nil
+		}
+
+		if err == nil && statusCode >= 200 && statusCode < 300 {
+			delivery.Success = true
+
+			break
+		}
+
+		// Client errors will not succeed by retrying, except when the receiver asks for the request to be retried
+		if statusCode >= 400 && statusCode < 500 && statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+			break
+		}
+	}
+
+	delivery.CompletedAt = time.Now().UTC()
+
+	return delivery, db.InsertWebhookDelivery(*delivery)
+}
+
+// SendWebhookRequest makes a single delivery attempt of the payload, signed using the secret of the webhook, and returns
+// the status code of the response.
+func SendWebhookRequest(webhook Webhook, event WebhookEvent, payload []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Webhooks.DeliveryTimeout)
+
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(payload))
+
+	if err != nil {
+		return 0, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "mcstatus.io webhooks")
+	req.Header.Set("X-Webhook-ID", webhook.ID)
+	req.Header.Set("X-Webhook-Event", event.Type)
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", fmt.Sprintf("sha256=%s", SignWebhookPayload(webhook.Secret, timestamp, payload)))
+
+	resp, err := webhookClient.Do(req)
+
+	if err != nil {
+		return 0, err
+	}
+
+	defer resp.Body.Close()
+
+	// The body is read so the connection can be reused by the next delivery, up to a limit so that a receiver cannot keep
+	// the delivery busy by sending a large response
+	if _, err = io.Copy(io.Discard, io.LimitReader(resp.Body, 1<<16)); err != nil {
+		return resp.StatusCode, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status code: %d", resp.StatusCode)
+	}
+
+	return resp.StatusCode, nil
+}
+
+// CheckWebhookDialAddress refuses connections to private, loopback, link-local and other non-public IP addresses unless
+// private URLs are allowed. The check runs on the address being dialed after the hostname was resolved, so a hostname
+// cannot resolve to a public address when the URL is registered and to an internal address when it is delivered to.
+func CheckWebhookDialAddress(network, address string, conn syscall.RawConn) error {
+	if config.Webhooks.AllowPrivateURLs {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+
+	if err != nil {
+		return err
+	}
+
+	ip, err := netip.ParseAddr(host)
+
+	if err != nil {
+		return err
+	}
+
+	ip = ip.Unmap()
+
+	if !ip.IsGlobalUnicast() || ip.IsPrivate() || sharedAddressSpace.Contains(ip) {
+		return fmt.Errorf("webhook deliveries to %s are not allowed", ip)
+	}
+
+	return nil
+}
+
+// SignWebhookPayload returns the hex encoded HMAC-SHA256 of the timestamp and payload joined by a period, which receivers
+// can use to verify that the delivery came from this server and was not replayed.
+func SignWebhookPayload(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(payload)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// BuildWebhookPayload encodes the event in the format of the webhook.
+func BuildWebhookPayload(webhook Webhook, event WebhookEvent) ([]byte, error) {
+	description := DescribeWebhookEvent(event)
+
+	color, ok := webhookEventColors[event.Type]
+
+	if !ok {
+		color = 0x3498DB
+	}
+
+	switch webhook.Format {
+	case WebhookFormatDiscord:
+		return json.Marshal(map[string]interface{}{
+			"embeds": []map[string]interface{}{
+				{
+					"title":       GetWebhookEventTitle(event),
+					"description": description,
+					"color":       color,
+					"timestamp":   event.Timestamp.Format(time.RFC3339),
+				},
+			},
+		})
+	case WebhookFormatSlack:
+		return json.Marshal(map[string]interface{}{
+			"text": description,
+			"attachments": []map[string]interface{}{
+				{
+					"color": fmt.Sprintf("#%06X", color),
+					"title": GetWebhookEventTitle(event),
+					"text":  description,
+					"ts":    event.Timestamp.Unix(),
+				},
+			},
+		})
+	default:
+		return json.Marshal(map[string]interface{}{
+			"webhook":     webhook.ID,
+			"description": description,
+			"event":       event,
+		})
+	}
+}
+
+// GetWebhookEventTitle returns the title of the event, which is the address of the server or the changed blocklist entry.
+func GetWebhookEventTitle(event WebhookEvent) string {
+	if change := event.BlocklistChange; change != nil {
+		if change.Pattern != nil {
+			return *change.Pattern
+		}
+
+		return change.Hash
+	}
+
+	return fmt.Sprintf("%s:%d", event.Address.Host, event.Address.Port)
+}
+
+// DescribeWebhookEvent returns a human readable description of the event.
+func DescribeWebhookEvent(event WebhookEvent) string {
+	address := GetWebhookEventTitle(event)
+
+	switch event.Type {
+	case WebhookRuleOffline:
+		return fmt.Sprintf("%s is now offline", address)
+	case WebhookRuleOnline:
+		return fmt.Sprintf("%s is back online", address)
+	case WebhookRuleVersionChanged:
+		return fmt.Sprintf("%s changed version from %s to %s", address, *event.Previous.Version, *event.Current.Version)
+	case WebhookRulePlayersAbove:
+		return fmt.Sprintf("%s rose above %d players (%d online)", address, *event.Rule.Threshold, *event.Current.PlayersOnline)
+	case WebhookRulePlayersBelow:
+		return fmt.Sprintf("%s fell below %d players (%d online)", address, *event.Rule.Threshold, *event.Current.PlayersOnline)
+	case WebhookRuleBlocklistAdded, WebhookRuleBlocklistRemoved:
+		{
+			action := "added to"
+
+			if event.Type == WebhookRuleBlocklistRemoved {
+				action = "removed from"
+			}
+
+			if servers := event.BlocklistChange.Servers; len(servers) > 0 {
+				return fmt.Sprintf("%s was %s the EULA blocked servers list, matching %s", address, action, strings.Join(servers, ", "))
+			}
+
+			return fmt.Sprintf("%s was %s the EULA blocked servers list", address, action)
+		}
+	default:
+		return fmt.Sprintf("%s triggered %s", address, event.Type)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// useWebhookConfig allows deliveries to the loopback test server without waiting on the retry backoff.
+func useWebhookConfig(t *testing.T, allowPrivateURLs bool) {
+	t.Helper()
+
+	previous := config.Webhooks
+
+	config.Webhooks.AllowPrivateURLs = allowPrivateURLs
+	config.Webhooks.RetryBackoff = time.Millisecond
+	config.Webhooks.DeliveryTimeout = time.Second
+
+	t.Cleanup(func() { config.Webhooks = previous })
+}
+
+func newTestWebhook(url string) Webhook {
+	return Webhook{
+		ID:     "test-webhook",
+		URL:    url,
+		Format: WebhookFormatJSON,
+		Secret: "test-secret",
+	}
+}
+
+func newTestWebhookEvent() WebhookEvent {
+	sample := &StatusSample{Timestamp: time.Now().UTC(), Online: true}
+
+	return WebhookEvent{
+		Type:      WebhookRuleOnline,
+		Rule:      WebhookRule{Type: WebhookRuleOnline},
+		Address:   WebhookAddress{Edition: "java", Host: "play.example.com", Port: 25565},
+		Previous:  sample,
+		Current:   sample,
+		Timestamp: sample.Timestamp,
+	}
+}
+
+// deliverTestWebhook delivers the event, ignoring the error of recording the delivery as MongoDB is not connected.
+func deliverTestWebhook(t *testing.T, webhook Webhook, event WebhookEvent, maxAttempts int) *WebhookDelivery {
+	t.Helper()
+
+	delivery, err := DeliverWebhook(webhook, event, maxAttempts)
+
+	if err != nil && !errors.Is(err, ErrMongoNotConnected) {
+		t.Fatal(err)
+	}
+
+	return delivery
+}
+
+func TestDeliverWebhookSignsPayload(t *testing.T) {
+	useWebhookConfig(t, true)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, err := io.ReadAll(req.Body)
+
+		if err != nil {
+			t.Error(err)
+		}
+
+		expected := "sha256=" + SignWebhookPayload("test-secret", req.Header.Get("X-Webhook-Timestamp"), body)
+
+		if signature := req.Header.Get("X-Webhook-Signature"); signature != expected {
+			t.Errorf("unexpected signature: got %s, expected %s", signature, expected)
+		}
+
+		if event := req.Header.Get("X-Webhook-Event"); event != WebhookRuleOnline {
+			t.Errorf("unexpected event header: %s", event)
+		}
+
+		var payload struct {
+			Webhook     string       `json:"webhook"`
+			Description string       `json:"description"`
+			Event       WebhookEvent `json:"event"`
+		}
+
+		if err = json.Unmarshal(body, &payload); err != nil {
+			t.Errorf("payload is not valid JSON: %v", err)
+		}
+
+		if payload.Webhook != "test-webhook" || payload.Event.Type != WebhookRuleOnline || payload.Event.Address.Host != "play.example.com" {
+			t.Errorf("unexpected payload: %s", body)
+		}
+
+		if !strings.Contains(payload.Description, "play.example.com:25565 is back online") {
+			t.Errorf("unexpected description: %s", payload.Description)
+		}
+
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	defer server.Close()
+
+	delivery := deliverTestWebhook(t, newTestWebhook(server.URL), newTestWebhookEvent(), 3)
+
+	if !delivery.Success || delivery.Attempts != 1 {
+		t.Errorf("expected a successful first attempt, got %+v", delivery)
+	}
+}
+
+func TestDeliverWebhookRetries(t *testing.T) {
+	useWebhookConfig(t, true)
+
+	tests := []struct {
+		name             string
+		statusCodes      []int
+		maxAttempts      int
+		expectedAttempts int
+		expectedSuccess  bool
+	}{
+		{"server error then success", []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}, 5, 3, true},
+		{"too many requests is retried", []int{http.StatusTooManyRequests, http.StatusOK}, 5, 2, true},
+		{"client error is not retried", []int{http.StatusBadRequest, http.StatusOK}, 5, 1, false},
+		{"gives up after max attempts", []int{http.StatusInternalServerError}, 3, 3, false},
+		{"single attempt", []int{http.StatusInternalServerError, http.StatusOK}, 1, 1, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var requests atomic.Int32
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				i := int(requests.Add(1)) - 1
+
+				w.WriteHeader(test.statusCodes[min(i, len(test.statusCodes)-1)])
+			}))
+
+			defer server.Close()
+
+			delivery := deliverTestWebhook(t, newTestWebhook(server.URL), newTestWebhookEvent(), test.maxAttempts)
+
+			if delivery.Attempts != test.expectedAttempts || int(requests.Load()) != test.expectedAttempts {
+				t.Errorf("expected %d attempts, got %d (%d requests)", test.expectedAttempts, delivery.Attempts, requests.Load())
+			}
+
+			if delivery.Success != test.expectedSuccess {
+				t.Errorf("expected success to be %t, got %+v", test.expectedSuccess, delivery)
+			}
+		})
+	}
+}
+
+func TestDeliverWebhookRejectsPrivateAddresses(t *testing.T) {
+	useWebhookConfig(t, false)
+
+	var requests atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		requests.Add(1)
+	}))
+
+	defer server.Close()
+
+	delivery := deliverTestWebhook(t, newTestWebhook(server.URL), newTestWebhookEvent(), 1)
+
+	if delivery.Success || requests.Load() != 0 {
+		t.Errorf("expected the loopback delivery to be refused, got %+v", delivery)
+	}
+
+	if delivery.Error == nil || !strings.Contains(*delivery.Error, "are not allowed") {
+		t.Errorf("unexpected delivery error: %v", delivery.Error)
+	}
+}
+
+func TestCheckWebhookDialAddress(t *testing.T) {
+	useWebhookConfig(t, false)
+
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:2800:220:1:248:1893:25c8:1946]:443", true},
+		{"127.0.0.1:80", false},
+		{"10.0.0.1:80", false},
+		{"172.16.5.4:80", false},
+		{"192.168.1.1:80", false},
+		{"100.64.0.1:80", false},
+		{"169.254.169.254:80", false},
+		{"0.0.0.0:80", false},
+		{"[::1]:80", false},
+		{"[fe80::1]:80", false},
+		{"[fd00::1]:80", false},
+		{"[::ffff:127.0.0.1]:80", false},
+	}
+
+	for _, test := range tests {
+		if err := CheckWebhookDialAddress("tcp", test.address, nil); (err == nil) != test.allowed {
+			t.Errorf("CheckWebhookDialAddress(%s) = %v, expected allowed to be %t", test.address, err, test.allowed)
+		}
+	}
+}
+
+func TestDeliverWebhookReusesConnections(t *testing.T) {
+	useWebhookConfig(t, true)
+
+	var connections atomic.Int32
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+
+	server.Config.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			connections.Add(1)
+		}
+	}
+
+	server.Start()
+
+	defer server.Close()
+
+	for i := 0; i < 10; i++ {
+		if delivery := deliverTestWebhook(t, newTestWebhook(server.URL), newTestWebhookEvent(), 1); !delivery.Success {
+			t.Fatalf("expected delivery %d to succeed, got %+v", i, delivery)
+		}
+	}
+
+	if count := connections.Load(); count != 1 {
+		t.Errorf("expected every delivery to reuse a single connection, got %d connections", count)
+	}
+}